@@ -18,8 +18,9 @@ type Authorizer interface {
 type authorizer struct {
 	config Config
 
-	client   *oidc.Client
-	verifier *oidc.IDTokenVerifier
+	client       *oidc.Client
+	verifier     *oidc.IDTokenVerifier
+	dpopVerifier *oidc.DPoPVerifier
 }
 
 func New(ctx context.Context, config Config) (Authorizer, error) {
@@ -34,6 +35,7 @@ func New(ctx context.Context, config Config) (Authorizer, error) {
 		verifier: client.Verifier(oidc.VerificationConfig{
 			ClientID: config.ClientID,
 		}),
+		dpopVerifier: newDPoPVerifier(),
 	}, nil
 }
 
@@ -44,6 +46,16 @@ func (a *authorizer) IsAuthorized(ctx context.Context, token string) error {
 		return fmt.Errorf("Unauthenticated. Verification failed. Err: %v", err)
 	}
 
+	if a.config.RequireCertificateBinding {
+		if err := checkCertificateBinding(ctx, idToken.Claims); err != nil {
+			return err
+		}
+	}
+
+	if err := checkDPoPBinding(ctx, a.dpopVerifier, a.config.RequireDPoP, token, idToken.Claims); err != nil {
+		return err
+	}
+
 	permsMap := map[string]interface{}{
 		a.config.PermsClaim: nil,
 	}
@@ -53,27 +65,41 @@ func (a *authorizer) IsAuthorized(ctx context.Context, token string) error {
 		return err
 	}
 
-	perms, ok := permsMap[a.config.PermsClaim].([]interface{})
+	permissions, err := permissionsFromClaim(a.config.PermsClaim, permsMap[a.config.PermsClaim])
+	if err != nil {
+		return err
+	}
+
+	return isAuthorized(a.config, idToken.Subject, permissions)
+}
+
+// permissionsFromClaim converts given claim value to list of permissions.
+func permissionsFromClaim(claimName string, claim interface{}) ([]string, error) {
+	perms, ok := claim.([]interface{})
 	if !ok {
-		return fmt.Errorf("Wrong type of %q claim. Expected []interface{}. Got: %v",
-			a.config.PermsClaim, reflect.TypeOf(permsMap[a.config.PermsClaim]))
+		return nil, fmt.Errorf("Wrong type of %q claim. Expected []interface{}. Got: %v",
+			claimName, reflect.TypeOf(claim))
 	}
 
 	var permissions []string
 	for _, permission := range perms {
 		permissionStr, ok := permission.(string)
 		if !ok {
-			return fmt.Errorf("Wrong type of permission inside %q claim. Expected string. Got: %v",
-				a.config.PermsClaim, reflect.TypeOf(permission))
+			return nil, fmt.Errorf("Wrong type of permission inside %q claim. Expected string. Got: %v",
+				claimName, reflect.TypeOf(permission))
 		}
 		permissions = append(permissions, permissionStr)
 	}
+	return permissions, nil
+}
 
-	if isAuthorized := a.config.PermCondition.isSatisfiedBy(permissions); isAuthorized {
+// isAuthorized returns nil if permissions satisfy configured permission condition.
+func isAuthorized(config Config, subject string, permissions []string) error {
+	if config.PermCondition.isSatisfiedBy(permissions) {
 		return nil
 	}
 
-	return fmt.Errorf("Unauthorized. User %q has permissions %v and needs to have permissions %s.", idToken.Subject, permissions, a.config.PermCondition.stringRepr)
+	return fmt.Errorf("Unauthorized. User %q has permissions %v and needs to have permissions %s.", subject, permissions, config.PermCondition.stringRepr)
 }
 
 func IsRequestAuthorized(req *http.Request, a Authorizer, headerName string) error {
@@ -82,9 +108,25 @@ func IsRequestAuthorized(req *http.Request, a Authorizer, headerName string) err
 		return fmt.Errorf("Unauthenticated. No %s header.", headerName)
 	}
 	parts := strings.Split(auth, " ")
-	if len(parts) < 2 || strings.ToLower(parts[0]) != "bearer" {
-		return fmt.Errorf("Unauthenticated. %s header does not have Bearer format.", headerName)
+	if len(parts) < 2 || (strings.ToLower(parts[0]) != "bearer" && strings.ToLower(parts[0]) != "dpop") {
+		return fmt.Errorf("Unauthenticated. %s header does not have Bearer or DPoP format.", headerName)
 	}
 
-	return a.IsAuthorized(req.Context(), parts[1])
+	ctx := req.Context()
+	if req.TLS != nil && len(req.TLS.PeerCertificates) > 0 {
+		ctx = ContextWithPeerCertificate(ctx, req.TLS.PeerCertificates[0])
+	}
+
+	if strings.ToLower(parts[0]) == "dpop" {
+		proofs := req.Header[http.CanonicalHeaderKey(oidc.DPoPHeader)]
+		if len(proofs) != 1 {
+			return fmt.Errorf("Unauthenticated. DPoP authorization scheme requires exactly one %s header.", oidc.DPoPHeader)
+		}
+		scheme := "http"
+		if req.TLS != nil {
+			scheme = "https"
+		}
+		ctx = ContextWithDPoPProof(ctx, proofs[0], req.Method, scheme+"://"+req.Host+req.URL.Path)
+	}
+	return a.IsAuthorized(ctx, parts[1])
 }
@@ -14,4 +14,13 @@ type Config struct {
 
 	// Permission condition that will authorize token.
 	PermCondition Condition
+
+	// RequireCertificateBinding requires token to be bound to the client certificate (RFC 8705). Thumbprint in token's
+	// "cnf.x5t#S256" claim must match the peer certificate of the request. See IsRequestAuthorized and
+	// ContextWithPeerCertificate.
+	RequireCertificateBinding bool
+
+	// RequireDPoP requires token to be DPoP-bound (RFC 9449). Tokens with "cnf.jkt" claim are always checked against
+	// DPoP proof of the request (htm, htu, iat, ath and jti replay). See IsRequestAuthorized and ContextWithDPoPProof.
+	RequireDPoP bool
 }
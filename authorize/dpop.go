@@ -0,0 +1,67 @@
+package authorize
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+
+	"github.com/jxsl13/oidc"
+)
+
+type dpopRequestCtxKey struct{}
+
+type dpopRequest struct {
+	proof  string
+	method string
+	url    string
+}
+
+// ContextWithDPoPProof returns context with DPoP proof and the method and absolute URL of the request it was sent
+// with. Authorizers check DPoP-bound tokens against it. IsRequestAuthorized does that automatically for requests with
+// "DPoP" authorization scheme. Use it directly if the service is behind a proxy that changes the request URL.
+func ContextWithDPoPProof(ctx context.Context, proof string, method string, url string) context.Context {
+	return context.WithValue(ctx, dpopRequestCtxKey{}, &dpopRequest{proof: proof, method: method, url: url})
+}
+
+func newDPoPVerifier() *oidc.DPoPVerifier {
+	return &oidc.DPoPVerifier{Replay: oidc.NewMemoryReplayCache()}
+}
+
+// checkDPoPBinding checks if DPoP proof from context is valid for the token and signed by the key from token's
+// "cnf.jkt" claim. Tokens without "cnf.jkt" claim are accepted only if DPoP is not required and sent as Bearer.
+func checkDPoPBinding(ctx context.Context, verifier *oidc.DPoPVerifier, required bool, token string, claims func(interface{}) error) error {
+	var c struct {
+		Cnf *oidc.Confirmation `json:"cnf"`
+	}
+	if err := claims(&c); err != nil {
+		// Should not happen.
+		return err
+	}
+	var jkt string
+	if c.Cnf != nil {
+		jkt = c.Cnf.JKT
+	}
+
+	req, ok := ctx.Value(dpopRequestCtxKey{}).(*dpopRequest)
+	if jkt == "" {
+		if required {
+			return fmt.Errorf("Unauthenticated. Token is not DPoP-bound. No cnf.jkt claim.")
+		}
+		if ok {
+			return fmt.Errorf("Unauthenticated. DPoP authorization scheme used with token that is not DPoP-bound.")
+		}
+		return nil
+	}
+	if !ok {
+		return fmt.Errorf("Unauthenticated. Token is DPoP-bound, but request has no DPoP proof.")
+	}
+
+	proofJKT, err := verifier.Verify(req.proof, req.method, req.url, token)
+	if err != nil {
+		return fmt.Errorf("Unauthenticated. Invalid DPoP proof. Err: %v", err)
+	}
+	if subtle.ConstantTimeCompare([]byte(jkt), []byte(proofJKT)) != 1 {
+		return fmt.Errorf("Unauthenticated. Token is bound to a different DPoP key than the proof.")
+	}
+	return nil
+}
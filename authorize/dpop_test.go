@@ -0,0 +1,90 @@
+package authorize
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jxsl13/oidc"
+	"github.com/jxsl13/oidc/testing"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsRequestAuthorized_DPoP(t *testing.T) {
+	oldKeySetExpiration := oidc.DefaultKeySetExpiration
+	oidc.DefaultKeySetExpiration = 0 * time.Second
+	defer func() {
+		oidc.DefaultKeySetExpiration = oldKeySetExpiration
+	}()
+
+	p := &oidc_testing.Provider{}
+	p.Setup(t)
+	p.MockDiscoveryCall()
+
+	testConfig := Config{
+		Provider:      p.IssuerTestSrv.URL,
+		ClientID:      "clientID",
+		PermCondition: Contains("secret-permission"),
+		PermsClaim:    "perms",
+	}
+	a, err := New(context.Background(), testConfig)
+	require.NoError(t, err)
+
+	prover, err := oidc.NewEphemeralDPoPProver()
+	require.NoError(t, err)
+	otherProver, err := oidc.NewEphemeralDPoPProver()
+	require.NoError(t, err)
+
+	const resourceURL = "https://example.com/resource"
+	isRequestAuthorized := func(scheme string, token string, prover *oidc.DPoPProver, method string, htu string) error {
+		req := httptest.NewRequest("GET", resourceURL+"?q=1", nil)
+		req.Header.Set("Authorization", scheme+" "+token)
+		if prover != nil {
+			proof, err := prover.Proof(method, htu, token)
+			require.NoError(t, err)
+			req.Header.Set(oidc.DPoPHeader, proof)
+		}
+		return IsRequestAuthorized(req, a, "Authorization")
+	}
+
+	// Token not bound to any key, but sent with DPoP scheme.
+	token, keys := p.NewIDToken(testConfig.ClientID, "sub1", "", map[string]interface{}{
+		"perms": []string{"secret-permission"},
+	})
+	p.MockPubKeysCall(keys)
+	require.Error(t, isRequestAuthorized("DPoP", token, prover, "GET", resourceURL), "token is not DPoP-bound - expected to be not authorized.")
+
+	boundToken, keys := p.NewIDToken(testConfig.ClientID, "sub1", "", map[string]interface{}{
+		"perms": []string{"secret-permission"},
+		"cnf":   map[string]string{"jkt": prover.Thumbprint()},
+	})
+
+	// Bound token sent as Bearer without proof.
+	p.MockPubKeysCall(keys)
+	require.Error(t, isRequestAuthorized("Bearer", boundToken, nil, "", ""), "no DPoP proof - expected to be not authorized.")
+
+	// Proof signed by different key.
+	p.MockPubKeysCall(keys)
+	require.Error(t, isRequestAuthorized("DPoP", boundToken, otherProver, "GET", resourceURL), "different key - expected to be not authorized.")
+
+	// Proof for different method or URL.
+	p.MockPubKeysCall(keys)
+	require.Error(t, isRequestAuthorized("DPoP", boundToken, prover, "POST", resourceURL), "different method - expected to be not authorized.")
+	p.MockPubKeysCall(keys)
+	require.Error(t, isRequestAuthorized("DPoP", boundToken, prover, "GET", "https://example.com/other"), "different URL - expected to be not authorized.")
+
+	// Proof matches.
+	p.MockPubKeysCall(keys)
+	require.NoError(t, isRequestAuthorized("DPoP", boundToken, prover, "GET", resourceURL), "token ok - expected to be authorized.")
+	require.Len(t, p.ExpectedRequests, 0)
+
+	// Unbound tokens are rejected if DPoP is required.
+	testConfig.RequireDPoP = true
+	p.MockDiscoveryCall()
+	a, err = New(context.Background(), testConfig)
+	require.NoError(t, err)
+	p.MockPubKeysCall(keys)
+	require.Error(t, isRequestAuthorized("Bearer", token, nil, "", ""), "DPoP required - expected to be not authorized.")
+	require.Len(t, p.ExpectedRequests, 0)
+}
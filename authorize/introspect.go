@@ -0,0 +1,166 @@
+package authorize
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jxsl13/oidc"
+)
+
+// IntrospectionConfig is a configuration of authorizer that validates (possibly opaque) access tokens using
+// token introspection (RFC 7662) instead of local ID token verification.
+type IntrospectionConfig struct {
+	Config
+
+	// ClientSecret is used together with ClientID to authenticate against introspection endpoint.
+	ClientSecret string
+	// ClientAuth specifies how the authorizer authenticates against introspection endpoint. If nil, HTTP Basic with
+	// ClientID and ClientSecret is used. See oidc.Config.ClientAuth.
+	ClientAuth oidc.ClientAuth
+
+	// CacheTTL is a maximum time for which introspection result of an active token is cached.
+	// Cached result never outlives token expiry. Zero disables caching.
+	CacheTTL time.Duration
+}
+
+type cachedIntrospection struct {
+	resp   *oidc.IntrospectionResponse
+	expiry time.Time
+}
+
+type introspectionAuthorizer struct {
+	config IntrospectionConfig
+
+	client       *oidc.Client
+	dpopVerifier *oidc.DPoPVerifier
+	timeNow      func() time.Time
+
+	cacheMu sync.Mutex
+	// Cache is keyed by SHA256 of the token, so raw tokens are not kept in memory.
+	cache map[[sha256.Size]byte]cachedIntrospection
+}
+
+// NewIntrospection constructs Authorizer that introspects given token on the provider's introspection endpoint and
+// checks PermCondition against PermsClaim of the introspection response. PermsClaim can be either JSON array of strings
+// or space-delimited string (e.g "scope").
+func NewIntrospection(ctx context.Context, config IntrospectionConfig) (Authorizer, error) {
+	client, err := oidc.NewClient(ctx, config.Provider)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create OIDC client agains %q provider. Err: %v", config.Provider, err)
+	}
+
+	if client.Discovery().IntrospectionURL == "" {
+		return nil, fmt.Errorf("Provider %q does not support token introspection", config.Provider)
+	}
+
+	return &introspectionAuthorizer{
+		config:       config,
+		client:       client,
+		dpopVerifier: newDPoPVerifier(),
+		timeNow:      time.Now,
+		cache:        map[[sha256.Size]byte]cachedIntrospection{},
+	}, nil
+}
+
+func (a *introspectionAuthorizer) IsAuthorized(ctx context.Context, token string) error {
+	resp, err := a.introspect(ctx, token)
+	if err != nil {
+		return fmt.Errorf("Unauthenticated. Introspection failed. Err: %v", err)
+	}
+
+	if !resp.Active {
+		return fmt.Errorf("Unauthenticated. Token is not active.")
+	}
+
+	// Introspection response does not need to include audience. If it does, it needs to be us.
+	if len(resp.Audience) > 0 && !contains(resp.Audience, a.config.ClientID) {
+		return fmt.Errorf("Unauthenticated. Expected Audience %q got %q", a.config.ClientID, resp.Audience)
+	}
+
+	if a.config.RequireCertificateBinding {
+		if err := checkCertificateBinding(ctx, resp.Claims); err != nil {
+			return err
+		}
+	}
+
+	if err := checkDPoPBinding(ctx, a.dpopVerifier, a.config.RequireDPoP, token, resp.Claims); err != nil {
+		return err
+	}
+
+	permsMap := map[string]interface{}{
+		a.config.PermsClaim: nil,
+	}
+	err = resp.Claims(&permsMap)
+	if err != nil {
+		// Should not happen.
+		return err
+	}
+
+	var permissions []string
+	if permsStr, ok := permsMap[a.config.PermsClaim].(string); ok {
+		// Space-delimited format as in "scope" claim.
+		permissions = strings.Fields(permsStr)
+	} else {
+		permissions, err = permissionsFromClaim(a.config.PermsClaim, permsMap[a.config.PermsClaim])
+		if err != nil {
+			return err
+		}
+	}
+
+	return isAuthorized(a.config.Config, resp.Subject, permissions)
+}
+
+// introspect returns cached introspection response or asks the provider.
+func (a *introspectionAuthorizer) introspect(ctx context.Context, token string) (*oidc.IntrospectionResponse, error) {
+	key := sha256.Sum256([]byte(token))
+	now := a.timeNow()
+
+	a.cacheMu.Lock()
+	cached, ok := a.cache[key]
+	a.cacheMu.Unlock()
+	if ok && now.Before(cached.expiry) {
+		return cached.resp, nil
+	}
+
+	resp, err := a.client.Introspect(ctx, oidc.Config{
+		ClientID:     a.config.ClientID,
+		ClientSecret: a.config.ClientSecret,
+		ClientAuth:   a.config.ClientAuth,
+	}, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if a.config.CacheTTL <= 0 || !resp.Active {
+		return resp, nil
+	}
+
+	expiry := now.Add(a.config.CacheTTL)
+	if resp.Expiry != 0 && resp.Expiry.Time().Before(expiry) {
+		expiry = resp.Expiry.Time()
+	}
+
+	a.cacheMu.Lock()
+	defer a.cacheMu.Unlock()
+	// Drop expired entries, so cache does not grow with tokens that are no longer used.
+	for k, c := range a.cache {
+		if !now.Before(c.expiry) {
+			delete(a.cache, k)
+		}
+	}
+	a.cache[key] = cachedIntrospection{resp: resp, expiry: expiry}
+	return resp, nil
+}
+
+func contains(sli []string, ele string) bool {
+	for _, s := range sli {
+		if s == ele {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,150 @@
+package authorize
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jxsl13/oidc"
+	"github.com/jxsl13/oidc/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+)
+
+func TestIntrospection_IsAuthorized(t *testing.T) {
+	p := &oidc_testing.Provider{}
+	p.Setup(t)
+	p.MockDiscoveryCall()
+
+	testConfig := IntrospectionConfig{
+		Config: Config{
+			Provider:      p.IssuerTestSrv.URL,
+			ClientID:      "clientID",
+			PermCondition: Contains("secret-permission"),
+			PermsClaim:    "scope",
+		},
+		ClientSecret: "secret",
+		CacheTTL:     1 * time.Minute,
+	}
+	a, err := NewIntrospection(context.Background(), testConfig)
+	require.NoError(t, err)
+
+	// Inactive token.
+	p.MockIntrospectionCall(http.StatusOK, `{"active": false}`)
+	require.Error(t, a.IsAuthorized(context.Background(), "token1"), "token is not active - expected to be not authorized.")
+
+	// Inactive token results are not cached.
+	p.MockIntrospectionCall(http.StatusOK, `{"active": false}`)
+	require.Error(t, a.IsAuthorized(context.Background(), "token1"), "token is not active - expected to be not authorized.")
+
+	// Wrong audience.
+	p.MockIntrospectionCall(http.StatusOK, `{"active": true, "aud": "other", "scope": "secret-permission"}`)
+	require.Error(t, a.IsAuthorized(context.Background(), "token2"), "token has wrong audience - expected to be not authorized.")
+
+	// Active, but without required permission.
+	p.MockIntrospectionCall(http.StatusOK, `{"active": true, "sub": "sub1", "scope": "openid email"}`)
+	require.EqualError(t, a.IsAuthorized(context.Background(), "token3"),
+		`Unauthorized. User "sub1" has permissions [openid email] and needs to have permissions secret-permission.`)
+
+	// Perms totally ok.
+	p.MockIntrospectionCall(http.StatusOK, fmt.Sprintf(
+		`{"active": true, "sub": "sub1", "aud": "clientID", "scope": "openid secret-permission", "exp": %d}`,
+		time.Now().Add(1*time.Hour).Unix(),
+	))
+	require.NoError(t, a.IsAuthorized(context.Background(), "token4"), "token ok - expected to be authorized.")
+	require.Len(t, p.ExpectedRequests, 0)
+
+	// Cached, no introspection call expected.
+	require.NoError(t, a.IsAuthorized(context.Background(), "token4"), "token ok - expected to be authorized from cache.")
+	require.Len(t, p.ExpectedRequests, 0)
+}
+
+func TestIntrospection_CacheBoundedByExpiry(t *testing.T) {
+	p := &oidc_testing.Provider{}
+	p.Setup(t)
+	p.MockDiscoveryCall()
+
+	testConfig := IntrospectionConfig{
+		Config: Config{
+			Provider:      p.IssuerTestSrv.URL,
+			ClientID:      "clientID",
+			PermCondition: Contains("perm1"),
+			PermsClaim:    "perms",
+		},
+		CacheTTL: 1 * time.Hour,
+	}
+	a, err := NewIntrospection(context.Background(), testConfig)
+	require.NoError(t, err)
+
+	now := time.Now()
+	a.(*introspectionAuthorizer).timeNow = func() time.Time { return now }
+
+	resp := fmt.Sprintf(`{"active": true, "sub": "sub1", "perms": ["perm1"], "exp": %d}`, now.Add(1*time.Minute).Unix())
+	p.MockIntrospectionCall(http.StatusOK, resp)
+	require.NoError(t, a.IsAuthorized(context.Background(), "token1"))
+	require.Len(t, p.ExpectedRequests, 0)
+
+	// Still cached.
+	now = now.Add(30 * time.Second)
+	require.NoError(t, a.IsAuthorized(context.Background(), "token1"))
+	require.Len(t, p.ExpectedRequests, 0)
+
+	// Token expired, so cache entry needs to expire as well, even though TTL is longer.
+	now = now.Add(1 * time.Minute)
+	p.MockIntrospectionCall(http.StatusOK, `{"active": false}`)
+	require.Error(t, a.IsAuthorized(context.Background(), "token1"))
+	require.Len(t, p.ExpectedRequests, 0)
+}
+
+func TestIntrospection_PrivateKeyJWT(t *testing.T) {
+	p := &oidc_testing.Provider{}
+	p.Setup(t)
+	p.MockDiscoveryCall()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	a, err := NewIntrospection(context.Background(), IntrospectionConfig{
+		Config: Config{
+			Provider:      p.IssuerTestSrv.URL,
+			ClientID:      "clientID",
+			PermCondition: Contains("perm1"),
+			PermsClaim:    "perms",
+		},
+		ClientAuth: &oidc.PrivateKeyJWT{Signer: key},
+	})
+	require.NoError(t, err)
+
+	p.MockIntrospectionCall(http.StatusOK, `{"active": true, "sub": "sub1", "perms": ["perm1"]}`)
+	p.ExpectedRequests[len(p.ExpectedRequests)-1].Check = func(r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		_, _, ok := r.BasicAuth()
+		assert.False(t, ok)
+		assert.Equal(t, "token1", r.PostForm.Get("token"))
+		assert.Equal(t, oidc.ClientAssertionTypeJWTBearer, r.PostForm.Get("client_assertion_type"))
+
+		jws, err := jose.ParseSigned(r.PostForm.Get("client_assertion"))
+		require.NoError(t, err)
+		payload, err := jws.Verify(&key.PublicKey)
+		require.NoError(t, err)
+
+		var claims struct {
+			Issuer   string `json:"iss"`
+			Subject  string `json:"sub"`
+			Audience string `json:"aud"`
+		}
+		require.NoError(t, json.Unmarshal(payload, &claims))
+		assert.Equal(t, "clientID", claims.Issuer)
+		assert.Equal(t, "clientID", claims.Subject)
+		assert.Equal(t, p.IssuerTestSrv.URL, claims.Audience)
+	}
+	require.NoError(t, a.IsAuthorized(context.Background(), "token1"))
+	require.Len(t, p.ExpectedRequests, 0)
+}
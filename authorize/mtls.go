@@ -0,0 +1,43 @@
+package authorize
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/x509"
+	"fmt"
+
+	"github.com/jxsl13/oidc"
+)
+
+type peerCertificateCtxKey struct{}
+
+// ContextWithPeerCertificate returns context with client certificate that was presented in TLS handshake.
+// Authorizers with RequireCertificateBinding check token binding against it. IsRequestAuthorized does that
+// automatically for TLS requests.
+func ContextWithPeerCertificate(ctx context.Context, cert *x509.Certificate) context.Context {
+	return context.WithValue(ctx, peerCertificateCtxKey{}, cert)
+}
+
+// checkCertificateBinding checks if token "cnf" claim is bound to the peer certificate from context.
+func checkCertificateBinding(ctx context.Context, claims func(interface{}) error) error {
+	cert, ok := ctx.Value(peerCertificateCtxKey{}).(*x509.Certificate)
+	if !ok || cert == nil {
+		return fmt.Errorf("Unauthenticated. Token needs to be certificate-bound, but no client certificate was presented.")
+	}
+
+	var c struct {
+		Cnf *oidc.Confirmation `json:"cnf"`
+	}
+	if err := claims(&c); err != nil {
+		// Should not happen.
+		return err
+	}
+	if c.Cnf == nil || c.Cnf.X5tS256 == "" {
+		return fmt.Errorf("Unauthenticated. Token is not certificate-bound. No cnf.x5t#S256 claim.")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(c.Cnf.X5tS256), []byte(oidc.CertificateThumbprint(cert))) != 1 {
+		return fmt.Errorf("Unauthenticated. Token is bound to a different certificate than presented one.")
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package authorize
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jxsl13/oidc"
+	"github.com/jxsl13/oidc/testing"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsRequestAuthorized_CertificateBinding(t *testing.T) {
+	oldKeySetExpiration := oidc.DefaultKeySetExpiration
+	oidc.DefaultKeySetExpiration = 0 * time.Second
+	defer func() {
+		oidc.DefaultKeySetExpiration = oldKeySetExpiration
+	}()
+
+	p := &oidc_testing.Provider{}
+	p.Setup(t)
+	p.MockDiscoveryCall()
+
+	testConfig := Config{
+		Provider:                  p.IssuerTestSrv.URL,
+		ClientID:                  "clientID",
+		PermCondition:             Contains("secret-permission"),
+		PermsClaim:                "perms",
+		RequireCertificateBinding: true,
+	}
+	a, err := New(context.Background(), testConfig)
+	require.NoError(t, err)
+
+	cert := &x509.Certificate{Raw: []byte("cert1")}
+	isRequestAuthorized := func(token string, cert *x509.Certificate) error {
+		req := httptest.NewRequest("GET", "https://example.com", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		if cert != nil {
+			req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+		}
+		return IsRequestAuthorized(req, a, "Authorization")
+	}
+
+	// Token not bound to any certificate.
+	token, keys := p.NewIDToken(testConfig.ClientID, "sub1", "", map[string]interface{}{
+		"perms": []string{"secret-permission"},
+	})
+	p.MockPubKeysCall(keys)
+	require.Error(t, isRequestAuthorized(token, cert), "token is not certificate-bound - expected to be not authorized.")
+
+	boundToken, keys := p.NewIDToken(testConfig.ClientID, "sub1", "", map[string]interface{}{
+		"perms": []string{"secret-permission"},
+		"cnf":   map[string]string{"x5t#S256": oidc.CertificateThumbprint(cert)},
+	})
+
+	// No client certificate.
+	p.MockPubKeysCall(keys)
+	require.Error(t, isRequestAuthorized(boundToken, nil), "no client certificate - expected to be not authorized.")
+
+	// Different client certificate.
+	p.MockPubKeysCall(keys)
+	require.Error(t, isRequestAuthorized(boundToken, &x509.Certificate{Raw: []byte("cert2")}), "different certificate - expected to be not authorized.")
+
+	// Certificate matches.
+	p.MockPubKeysCall(keys)
+	require.NoError(t, isRequestAuthorized(boundToken, cert), "token ok - expected to be authorized.")
+	require.Len(t, p.ExpectedRequests, 0)
+}
@@ -0,0 +1,185 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+// BackChannelLogoutEvent is the member of logout token "events" claim that identifies the token as logout token.
+const BackChannelLogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
+
+// LogoutToken is a token sent by provider directly to RP to log out the user as described in
+// https://openid.net/specs/openid-connect-backchannel-1_0.html#LogoutToken.
+type LogoutToken struct {
+	Issuer   string      `json:"iss"`
+	Audience Audience    `json:"aud"`
+	Expiry   NumericDate `json:"exp"`
+	IssuedAt NumericDate `json:"iat"`
+	JWTID    string      `json:"jti"`
+
+	// NotBefore is optional "nbf" claim.
+	NotBefore NumericDate `json:"nbf,omitempty"`
+
+	// Subject is the user being logged out. Either Subject or SessionID (or both) is present.
+	Subject string `json:"sub,omitempty"`
+	// SessionID is the provider session ID ("sid" claim) being terminated.
+	SessionID string `json:"sid,omitempty"`
+
+	Events map[string]json.RawMessage `json:"events"`
+
+	// Nonce is prohibited in logout tokens. It is there only to be able to reject tokens that have it.
+	Nonce string `json:"nonce,omitempty"`
+
+	// Tenant resolved from the issuer for multi-tenant providers. See WithIssuerTemplate.
+	Tenant string `json:"-"`
+}
+
+// LogoutTokenVerifier verifies back-channel logout tokens. Signature, issuer and audience are verified using
+// the same key set and configuration as ID tokens.
+type LogoutTokenVerifier struct {
+	verifier *IDTokenVerifier
+	replay   ReplayCache
+}
+
+// NewLogoutTokenVerifier constructs LogoutTokenVerifier from the ID token verifier. Every logout token jti is stored in
+// replay cache until token expiry. If replay is nil, in-memory cache is used.
+// NOTE: ClaimNonce of the ID token verifier config is ignored, logout tokens must not contain nonce.
+func NewLogoutTokenVerifier(verifier *IDTokenVerifier, replay ReplayCache) *LogoutTokenVerifier {
+	if replay == nil {
+		replay = NewMemoryReplayCache()
+	}
+	return &LogoutTokenVerifier{verifier: verifier, replay: replay}
+}
+
+// LogoutTokenVerifier returns verifier for back-channel logout tokens that uses in-memory replay cache.
+func (c *Client) LogoutTokenVerifier(cfg VerificationConfig) *LogoutTokenVerifier {
+	return NewLogoutTokenVerifier(c.Verifier(cfg), nil)
+}
+
+// Verify parses and verifies raw logout token as described in
+// https://openid.net/specs/openid-connect-backchannel-1_0.html#Validation.
+// Encrypted logout tokens (JWE) are decrypted with the verifier config's decryption keys first.
+func (v *LogoutTokenVerifier) Verify(ctx context.Context, rawLogoutToken string) (*LogoutToken, error) {
+	if isJWE(rawLogoutToken) {
+		plaintext, err := decryptJWE(rawLogoutToken, v.verifier.cfg)
+		if err != nil {
+			return nil, fmt.Errorf("oidc: failed to decrypt logout token: %v", err)
+		}
+		rawLogoutToken = string(plaintext)
+	}
+
+	jws, err := jose.ParseSigned(rawLogoutToken)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: malformed jwt: %v", err)
+	}
+
+	payload, err := parseJWT(rawLogoutToken)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: malformed jwt: %v", err)
+	}
+	var token LogoutToken
+	if err := json.Unmarshal(payload, &token); err != nil {
+		return nil, fmt.Errorf("oidc: failed to unmarshal claims: %v", err)
+	}
+
+	cfg := v.verifier.cfg
+	token.Tenant, err = v.verifier.issuer.validateToken(token.Issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.ClientID == "" {
+		return nil, fmt.Errorf("oidc: Invalid configuration. ClientID must be provided")
+	}
+	if !contains(token.Audience, cfg.ClientID) {
+		return nil, fmt.Errorf("oidc: expected Audience %q got %q", cfg.ClientID, token.Audience)
+	}
+
+	if token.Expiry == 0 {
+		return nil, errors.New("oidc: logout token has no exp claim")
+	}
+	if token.IssuedAt == 0 {
+		return nil, errors.New("oidc: logout token has no iat claim")
+	}
+	if err := v.verifier.verifyTimes(token.Expiry, token.NotBefore, token.IssuedAt); err != nil {
+		return nil, err
+	}
+
+	if token.Subject == "" && token.SessionID == "" {
+		return nil, errors.New("oidc: logout token must contain sub or sid claim")
+	}
+	if token.Nonce != "" {
+		return nil, errors.New("oidc: logout token must not contain nonce claim")
+	}
+	event, ok := token.Events[BackChannelLogoutEvent]
+	if !ok {
+		return nil, fmt.Errorf("oidc: logout token events claim does not contain %s member", BackChannelLogoutEvent)
+	}
+	var eventObj map[string]interface{}
+	if err := json.Unmarshal(event, &eventObj); err != nil || eventObj == nil {
+		return nil, fmt.Errorf("oidc: logout token %s event must be a JSON object", BackChannelLogoutEvent)
+	}
+	if token.JWTID == "" {
+		return nil, errors.New("oidc: logout token has no jti claim")
+	}
+
+	if err := v.verifier.verifySignature(ctx, jws, payload); err != nil {
+		return nil, err
+	}
+
+	// Store jti only for tokens with valid signature, so nobody can block legitimate tokens by guessing jti.
+	if !v.replay.Add(token.Issuer+" "+token.JWTID, token.Expiry.Time()) {
+		return nil, fmt.Errorf("oidc: logout token %q was already used", token.JWTID)
+	}
+	return &token, nil
+}
+
+// TerminateSessionFunc terminates RP sessions of the user that logged out at the provider. sid is provider session ID
+// and sub is the user. One of them can be empty.
+type TerminateSessionFunc func(ctx context.Context, sid string, sub string) error
+
+// BackChannelLogoutHandler returns http.Handler that receives back-channel logout requests (POST with form encoded
+// logout_token), verifies the logout token and calls terminate.
+// It responds 200 on success and 400 with OAuth error JSON if logout token is invalid or session termination failed
+// as described in https://openid.net/specs/openid-connect-backchannel-1_0.html#BCResponse.
+func BackChannelLogoutHandler(verifier *LogoutTokenVerifier, terminate TerminateSessionFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		rawLogoutToken := r.PostFormValue("logout_token")
+		if rawLogoutToken == "" {
+			writeLogoutError(w, ErrorCodeInvalidRequest, "missing logout_token")
+			return
+		}
+
+		token, err := verifier.Verify(r.Context(), rawLogoutToken)
+		if err != nil {
+			writeLogoutError(w, ErrorCodeInvalidRequest, err.Error())
+			return
+		}
+
+		if err := terminate(r.Context(), token.SessionID, token.Subject); err != nil {
+			writeLogoutError(w, "logout_failed", err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func writeLogoutError(w http.ResponseWriter, code string, desc string) {
+	b, _ := json.Marshal(map[string]string{"error": code, "error_description": desc})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	w.Write(b)
+}
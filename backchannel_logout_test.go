@@ -0,0 +1,231 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwplotka/go-jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+)
+
+type staticKeySet []jose.JSONWebKey
+
+func (s staticKeySet) Keys(context.Context) ([]jose.JSONWebKey, error) {
+	return s, nil
+}
+
+func TestLogoutTokenVerifier(t *testing.T) {
+	builder, err := jwt.NewDefaultBuilder()
+	require.NoError(t, err)
+
+	now := time.Now()
+	validClaims := func() map[string]interface{} {
+		return map[string]interface{}{
+			"iss":    exampleIssuer,
+			"aud":    "client1",
+			"iat":    now.Unix(),
+			"exp":    now.Add(2 * time.Minute).Unix(),
+			"jti":    randomString(16),
+			"sub":    "subject1",
+			"sid":    "session1",
+			"events": map[string]interface{}{BackChannelLogoutEvent: map[string]interface{}{}},
+		}
+	}
+
+	for _, tcase := range []struct {
+		name    string
+		modify  func(c map[string]interface{})
+		wantErr string
+	}{
+		{name: "valid", modify: func(map[string]interface{}) {}},
+		{name: "only sid", modify: func(c map[string]interface{}) { delete(c, "sub") }},
+		{name: "no sid nor sub", modify: func(c map[string]interface{}) { delete(c, "sub"); delete(c, "sid") }, wantErr: "oidc: logout token must contain sub or sid claim"},
+		{name: "nonce", modify: func(c map[string]interface{}) { c["nonce"] = "nonce1" }, wantErr: "oidc: logout token must not contain nonce claim"},
+		{name: "no events", modify: func(c map[string]interface{}) { delete(c, "events") }, wantErr: "oidc: logout token events claim does not contain " + BackChannelLogoutEvent + " member"},
+		{name: "event not object", modify: func(c map[string]interface{}) {
+			c["events"] = map[string]interface{}{BackChannelLogoutEvent: "yes"}
+		}, wantErr: "oidc: logout token " + BackChannelLogoutEvent + " event must be a JSON object"},
+		{name: "no jti", modify: func(c map[string]interface{}) { delete(c, "jti") }, wantErr: "oidc: logout token has no jti claim"},
+		{name: "no iat", modify: func(c map[string]interface{}) { delete(c, "iat") }, wantErr: "oidc: logout token has no iat claim"},
+		{name: "expired", modify: func(c map[string]interface{}) { c["exp"] = now.Add(-1 * time.Minute).Unix() }, wantErr: "oidc: token is expired"},
+		{name: "issued in the future", modify: func(c map[string]interface{}) { c["iat"] = now.Add(1 * time.Minute).Unix() }, wantErr: "oidc: token is issued in the future"},
+		{name: "not valid yet", modify: func(c map[string]interface{}) { c["nbf"] = now.Add(1 * time.Minute).Unix() }, wantErr: "oidc: token is not valid yet"},
+		{name: "wrong audience", modify: func(c map[string]interface{}) { c["aud"] = "client2" }, wantErr: "oidc: expected Audience"},
+		{name: "wrong issuer", modify: func(c map[string]interface{}) { c["iss"] = "https://issuer2.org" }, wantErr: "oidc: id token issued by a different provider"},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			claims := validClaims()
+			tcase.modify(claims)
+			token, err := builder.JWS().Claims(claims).CompactSerialize()
+			require.NoError(t, err)
+
+			v := NewLogoutTokenVerifier(newVerifier(
+				staticKeySet{builder.PublicJWK()},
+				VerificationConfig{ClientID: "client1"},
+				issuerValidator{issuer: exampleIssuer},
+				nil,
+			), nil)
+			logoutToken, err := v.Verify(context.Background(), token)
+			if tcase.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tcase.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, claims["jti"], logoutToken.JWTID)
+			assert.Equal(t, "session1", logoutToken.SessionID)
+
+			// Replay.
+			_, err = v.Verify(context.Background(), token)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "was already used")
+		})
+	}
+}
+
+func TestLogoutTokenVerifier_Encrypted(t *testing.T) {
+	builder, err := jwt.NewDefaultBuilder()
+	require.NoError(t, err)
+
+	now := time.Now()
+	signed, err := builder.JWS().Claims(map[string]interface{}{
+		"iss":    exampleIssuer,
+		"aud":    "client1",
+		"iat":    now.Unix(),
+		"exp":    now.Add(2 * time.Minute).Unix(),
+		"jti":    randomString(16),
+		"sid":    "session1",
+		"events": map[string]interface{}{BackChannelLogoutEvent: map[string]interface{}{}},
+	}).CompactSerialize()
+	require.NoError(t, err)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	encrypter, err := jose.NewEncrypter(jose.A256GCM, jose.Recipient{Algorithm: jose.RSA_OAEP_256, Key: &rsaKey.PublicKey}, (&jose.EncrypterOptions{}).WithContentType("JWT"))
+	require.NoError(t, err)
+	jwe, err := encrypter.Encrypt([]byte(signed))
+	require.NoError(t, err)
+	token, err := jwe.CompactSerialize()
+	require.NoError(t, err)
+
+	_, err = NewLogoutTokenVerifier(newVerifier(
+		staticKeySet{builder.PublicJWK()},
+		VerificationConfig{ClientID: "client1"},
+		issuerValidator{issuer: exampleIssuer},
+		nil,
+	), nil).Verify(context.Background(), token)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "oidc: failed to decrypt logout token")
+
+	logoutToken, err := NewLogoutTokenVerifier(newVerifier(
+		staticKeySet{builder.PublicJWK()},
+		VerificationConfig{ClientID: "client1", DecryptionKeys: []jose.JSONWebKey{{Key: rsaKey, Use: "enc"}}},
+		issuerValidator{issuer: exampleIssuer},
+		nil,
+	), nil).Verify(context.Background(), token)
+	require.NoError(t, err)
+	assert.Equal(t, "session1", logoutToken.SessionID)
+}
+
+func TestLogoutTokenVerifier_Unsigned(t *testing.T) {
+	now := time.Now()
+	claims, err := json.Marshal(map[string]interface{}{
+		"iss":    exampleIssuer,
+		"aud":    "client1",
+		"iat":    now.Unix(),
+		"exp":    now.Add(2 * time.Minute).Unix(),
+		"jti":    randomString(16),
+		"sid":    "session1",
+		"events": map[string]interface{}{BackChannelLogoutEvent: map[string]interface{}{}},
+	})
+	require.NoError(t, err)
+	token := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." + base64.RawURLEncoding.EncodeToString(claims) + "."
+
+	// Unsigned logout tokens are rejected even if "none" is supported for ID tokens.
+	_, err = NewLogoutTokenVerifier(newVerifier(
+		staticKeySet{},
+		VerificationConfig{ClientID: "client1", SupportedSigningAlgs: []string{"none"}},
+		issuerValidator{issuer: exampleIssuer},
+		nil,
+	), nil).Verify(context.Background(), token)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "oidc: unsigned jwt is not allowed")
+}
+
+func TestBackChannelLogoutHandler(t *testing.T) {
+	builder, err := jwt.NewDefaultBuilder()
+	require.NoError(t, err)
+
+	v := NewLogoutTokenVerifier(newVerifier(
+		staticKeySet{builder.PublicJWK()},
+		VerificationConfig{ClientID: "client1"},
+		issuerValidator{issuer: exampleIssuer},
+		nil,
+	), nil)
+
+	var terminated []string
+	terminateErr := error(nil)
+	h := BackChannelLogoutHandler(v, func(_ context.Context, sid string, sub string) error {
+		terminated = append(terminated, sid+"/"+sub)
+		return terminateErr
+	})
+
+	post := func(logoutToken string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/backchannel_logout", strings.NewReader(url.Values{"logout_token": {logoutToken}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	newLogoutToken := func() string {
+		token, err := builder.JWS().Claims(map[string]interface{}{
+			"iss":    exampleIssuer,
+			"aud":    "client1",
+			"iat":    time.Now().Unix(),
+			"exp":    time.Now().Add(2 * time.Minute).Unix(),
+			"jti":    randomString(16),
+			"sid":    "session1",
+			"events": map[string]interface{}{BackChannelLogoutEvent: map[string]interface{}{}},
+		}).CompactSerialize()
+		require.NoError(t, err)
+		return token
+	}
+
+	logoutToken := newLogoutToken()
+	rec := post(logoutToken)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+	assert.Equal(t, []string{"session1/"}, terminated)
+
+	// Replayed token.
+	rec = post(logoutToken)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	var errResp TokenError
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &errResp))
+	assert.Equal(t, ErrorCodeInvalidRequest, errResp.Code)
+
+	rec = post("")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	terminateErr = errors.New("session store unavailable")
+	rec = post(newLogoutToken())
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Len(t, terminated, 2)
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/backchannel_logout", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
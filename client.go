@@ -9,10 +9,10 @@ import (
 	"io"
 	"io/ioutil"
 	"mime"
-	"net"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -25,8 +25,12 @@ const (
 
 	GrantTypeAuthCode     = "authorization_code"
 	GrantTypeRefreshToken = "refresh_token"
+	// GrantTypeClientCredentials is used by clients to obtain token on their own behalf (e.g service-to-service).
+	GrantTypeClientCredentials = "client_credentials"
 	// GrantTypeServiceAccount is a custom ServiceAccount to support exchanging SA for ID token.
 	GrantTypeServiceAccount = "service_account"
+	// GrantTypeDeviceCode is a Device Authorization Grant as defined in RFC 8628.
+	GrantTypeDeviceCode = "urn:ietf:params:oauth:grant-type:device_code"
 
 	ResponseTypeCode    = "code"     // Authorization Code flow
 	ResponseTypeToken   = "token"    // Implicit flow for frontend apps.
@@ -35,60 +39,58 @@ const (
 	DiscoveryEndpoint = "/.well-known/openid-configuration"
 )
 
-// HTTPClientCtxKey is Context key which is used to fetch custom HTTP.Client.
-// Used to pass special HTTP client (e.g with non-default timeout) or for tests.
-var HTTPClientCtxKey struct{}
-
-// doRequest performs HTTP request using our default client or client given by context like this:
-//
-//     context.WithValue(ctx, oidc.HTTPClientCtxKey, client)
-//
-func doRequest(ctx context.Context, req *http.Request) (*http.Response, error) {
-	// Create new transport before using it with exactly the same params as default one. Don't use it directly, because
-	// we don't want to depend on the default one.
-	newTransport := &http.Transport{
-		Proxy: http.ProxyFromEnvironment,
-		DialContext: (&net.Dialer{
-			Timeout:   30 * time.Second,
-			KeepAlive: 30 * time.Second,
-			DualStack: true,
-		}).DialContext,
-		MaxIdleConns:          100,
-		IdleConnTimeout:       90 * time.Second,
-		TLSHandshakeTimeout:   10 * time.Second,
-		ExpectContinueTimeout: 1 * time.Second,
-	}
-	client := &http.Client{
-		Transport: newTransport,
-	}
-	if c, ok := ctx.Value(HTTPClientCtxKey).(*http.Client); ok {
-		client = c
-	}
-	return client.Do(req.WithContext(ctx))
-}
-
 // Config is client configuration that contains all required client details to communicate with OIDC server.
 type Config struct {
 	ClientID     string
 	ClientSecret string
 	RedirectURL  string
 	Scopes       []string
+
+	// ClientAuth specifies how the client authenticates against provider's token, revocation and introspection endpoints.
+	// If nil, HTTP Basic with ClientID and ClientSecret is used (even if ClientSecret is empty). Set PublicClient
+	// to pass only client_id form value.
+	ClientAuth ClientAuth
+
+	// RequestObjectSigner signs authorization request parameters as request object (JAR, RFC 9101) in
+	// SignedAuthCodeURL, RequestObject and PushAuthorizationRequest.
+	RequestObjectSigner *RequestObjectSigner
+
+	// DPoP requests sender-constrained tokens (RFC 9449). Proofs are attached to token requests and returned tokens
+	// carry the prover, so Token.AuthorizeRequest sends proofs to resource servers as well.
+	DPoP *DPoPProver
 }
 
 // Client represents an OpenID Connect client.
 type Client struct {
-	issuer string
+	issuer issuerValidator
+
+	// httpClient is shared by all requests to the provider.
+	httpClient *http.Client
+	// mtlsClients are HTTP clients with client certificates, created on first use of given TLSClientAuth.
+	mtlsClientsMu sync.Mutex
+	mtlsClients   map[*TLSClientAuth]*http.Client
 
+	// mu guards provider metadata and key set, which can be swapped by Refresh.
+	mu sync.RWMutex
 	// Raw claims returned by the server on discovery endpoint.
 	rawDiscoveryClaims []byte
 	discovery          DiscoveryJSON
+	keySet             keySet
 
-	keySet keySet
+	// Discovery refresh state.
+	lastRefresh    time.Time
+	lastRefreshErr error
+	// discoveryExpiry is when discovery document should be re-fetched according to response cache headers.
+	// Zero if no cache headers were returned.
+	discoveryExpiry time.Time
 
 	cfg Config
 }
 
-// DiscoveryJSON is structure expected by Discovery endpoint.
+// DiscoveryJSON is structure expected by Discovery endpoint. It models OpenID Provider Metadata
+// (OpenID Connect Discovery 1.0 section 3) together with OAuth 2.0 Authorization Server Metadata (RFC 8414) and
+// metadata registered by the extensions this package supports.
+// Fields not listed here can be still accessed using Client.Claims.
 type DiscoveryJSON struct {
 	Issuer        string `json:"issuer"`
 	AuthURL       string `json:"authorization_endpoint"`
@@ -96,44 +98,127 @@ type DiscoveryJSON struct {
 	JWKSURL       string `json:"jwks_uri"`
 	UserInfoURL   string `json:"userinfo_endpoint"`
 	RevocationURL string `json:"revocation_endpoint"`
+
+	DeviceAuthorizationURL string `json:"device_authorization_endpoint"`
+	IntrospectionURL       string `json:"introspection_endpoint"`
+
+	MTLSEndpointAliases *MTLSEndpointAliases `json:"mtls_endpoint_aliases,omitempty"`
+
+	RegistrationURL               string `json:"registration_endpoint,omitempty"`
+	EndSessionURL                 string `json:"end_session_endpoint,omitempty"`
+	CheckSessionIFrameURL         string `json:"check_session_iframe,omitempty"`
+	PushedAuthorizationRequestURL string `json:"pushed_authorization_request_endpoint,omitempty"`
+	ServiceDocumentationURL       string `json:"service_documentation,omitempty"`
+	OPPolicyURL                   string `json:"op_policy_uri,omitempty"`
+	OPTOSURL                      string `json:"op_tos_uri,omitempty"`
+
+	ScopesSupported        []string `json:"scopes_supported,omitempty"`
+	ResponseTypesSupported []string `json:"response_types_supported,omitempty"`
+	ResponseModesSupported []string `json:"response_modes_supported,omitempty"`
+	// GrantTypesSupported is empty if not specified. RFC 8414 defines the default as "authorization_code" and
+	// "implicit", but many providers omit it while supporting more grant types, so SupportsGrantType treats empty
+	// value as "not advertised" and does not apply the default.
+	GrantTypesSupported           []string `json:"grant_types_supported,omitempty"`
+	ACRValuesSupported            []string `json:"acr_values_supported,omitempty"`
+	SubjectTypesSupported         []string `json:"subject_types_supported,omitempty"`
+	DisplayValuesSupported        []string `json:"display_values_supported,omitempty"`
+	ClaimTypesSupported           []string `json:"claim_types_supported,omitempty"`
+	ClaimsSupported               []string `json:"claims_supported,omitempty"`
+	ClaimsLocalesSupported        []string `json:"claims_locales_supported,omitempty"`
+	UILocalesSupported            []string `json:"ui_locales_supported,omitempty"`
+	CodeChallengeMethodsSupported []string `json:"code_challenge_methods_supported,omitempty"`
+
+	IDTokenSigningAlgValuesSupported    []string `json:"id_token_signing_alg_values_supported,omitempty"`
+	IDTokenEncryptionAlgValuesSupported []string `json:"id_token_encryption_alg_values_supported,omitempty"`
+	IDTokenEncryptionEncValuesSupported []string `json:"id_token_encryption_enc_values_supported,omitempty"`
+
+	UserInfoSigningAlgValuesSupported    []string `json:"userinfo_signing_alg_values_supported,omitempty"`
+	UserInfoEncryptionAlgValuesSupported []string `json:"userinfo_encryption_alg_values_supported,omitempty"`
+	UserInfoEncryptionEncValuesSupported []string `json:"userinfo_encryption_enc_values_supported,omitempty"`
+
+	RequestObjectSigningAlgValuesSupported    []string `json:"request_object_signing_alg_values_supported,omitempty"`
+	RequestObjectEncryptionAlgValuesSupported []string `json:"request_object_encryption_alg_values_supported,omitempty"`
+	RequestObjectEncryptionEncValuesSupported []string `json:"request_object_encryption_enc_values_supported,omitempty"`
+
+	// Authorization response signing and encryption (JARM).
+	AuthorizationSigningAlgValuesSupported    []string `json:"authorization_signing_alg_values_supported,omitempty"`
+	AuthorizationEncryptionAlgValuesSupported []string `json:"authorization_encryption_alg_values_supported,omitempty"`
+	AuthorizationEncryptionEncValuesSupported []string `json:"authorization_encryption_enc_values_supported,omitempty"`
+
+	// TokenEndpointAuthMethodsSupported defaults to "client_secret_basic" if not specified.
+	TokenEndpointAuthMethodsSupported                  []string `json:"token_endpoint_auth_methods_supported,omitempty"`
+	TokenEndpointAuthSigningAlgValuesSupported         []string `json:"token_endpoint_auth_signing_alg_values_supported,omitempty"`
+	RevocationEndpointAuthMethodsSupported             []string `json:"revocation_endpoint_auth_methods_supported,omitempty"`
+	RevocationEndpointAuthSigningAlgValuesSupported    []string `json:"revocation_endpoint_auth_signing_alg_values_supported,omitempty"`
+	IntrospectionEndpointAuthMethodsSupported          []string `json:"introspection_endpoint_auth_methods_supported,omitempty"`
+	IntrospectionEndpointAuthSigningAlgValuesSupported []string `json:"introspection_endpoint_auth_signing_alg_values_supported,omitempty"`
+
+	DPoPSigningAlgValuesSupported []string `json:"dpop_signing_alg_values_supported,omitempty"`
+
+	ClaimsParameterSupported  bool `json:"claims_parameter_supported,omitempty"`
+	RequestParameterSupported bool `json:"request_parameter_supported,omitempty"`
+	// RequestURIParameterSupported defaults to true if not specified.
+	RequestURIParameterSupported               *bool `json:"request_uri_parameter_supported,omitempty"`
+	RequireRequestURIRegistration              bool  `json:"require_request_uri_registration,omitempty"`
+	RequirePushedAuthorizationRequests         bool  `json:"require_pushed_authorization_requests,omitempty"`
+	AuthorizationResponseISSParameterSupported bool  `json:"authorization_response_iss_parameter_supported,omitempty"`
+	TLSClientCertificateBoundAccessTokens      bool  `json:"tls_client_certificate_bound_access_tokens,omitempty"`
+
+	FrontchannelLogoutSupported        bool `json:"frontchannel_logout_supported,omitempty"`
+	FrontchannelLogoutSessionSupported bool `json:"frontchannel_logout_session_supported,omitempty"`
+	BackchannelLogoutSupported         bool `json:"backchannel_logout_supported,omitempty"`
+	BackchannelLogoutSessionSupported  bool `json:"backchannel_logout_session_supported,omitempty"`
+}
+
+// ClientOption configures Client constructed by NewClient.
+type ClientOption func(*clientOptions)
+
+// clientOptions groups issuer validation options (issuer.go) and HTTP options (http.go).
+type clientOptions struct {
+	issuerOptions
+	httpOptions
 }
 
 // NewClient uses the OpenID Connect discovery mechanism to construct a Client.
-func NewClient(ctx context.Context, issuer string) (*Client, error) {
-	wellKnown := strings.TrimSuffix(issuer, "/") + DiscoveryEndpoint
-	req, err := http.NewRequest("GET", wellKnown, nil)
-	if err != nil {
-		return nil, err
+// Discovery is fetched only once. Use Refresh or RefreshPeriodically for long-running processes, so
+// the client follows provider changes.
+func NewClient(ctx context.Context, issuer string, opts ...ClientOption) (*Client, error) {
+	var o clientOptions
+	for _, opt := range opts {
+		opt(&o)
 	}
-	resp, err := doRequest(ctx, req)
+
+	issuerValidator, err := newIssuerValidator(issuer, o.issuerOptions)
 	if err != nil {
 		return nil, err
 	}
-	body, err := ioutil.ReadAll(resp.Body)
+
+	httpClient, err := o.newHTTPClient()
 	if err != nil {
 		return nil, err
 	}
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("%s: %s", resp.Status, body)
-	}
-	defer resp.Body.Close()
-	var p DiscoveryJSON
-	if err := json.Unmarshal(body, &p); err != nil {
-		return nil, fmt.Errorf("oidc: failed to decode provider discovery object: %v", err)
+
+	c := &Client{
+		issuer:     issuerValidator,
+		httpClient: httpClient,
 	}
-	if p.Issuer != issuer {
-		return nil, fmt.Errorf("oidc: issuer did not match the issuer returned by provider, expected %q got %q", issuer, p.Issuer)
+
+	d, err := c.fetchDiscovery(ctx)
+	if err != nil {
+		return nil, err
 	}
-	return &Client{
-		issuer:             p.Issuer,
-		discovery:          p,
-		rawDiscoveryClaims: body,
-		keySet:             newCachedKeySet(newRemoteKeySet(p.JWKSURL), DefaultKeySetExpiration, time.Now),
-	}, nil
+	c.discovery = d.metadata
+	c.rawDiscoveryClaims = d.raw
+	c.keySet = c.newKeySet(d.metadata.JWKSURL)
+	c.lastRefresh = d.fetched
+	c.discoveryExpiry = d.expiry
+	return c, nil
 }
 
 // Discovery returns standard discovery fields held by OIDC provider we point to.
 func (c *Client) Discovery() DiscoveryJSON {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	return c.discovery
 }
 
@@ -151,10 +236,14 @@ func (c *Client) Discovery() DiscoveryJSON {
 // For a list of fields defined by the OpenID Connect spec see:
 // https://openid.net/specs/openid-connect-discovery-1_0.html#ProviderMetadata
 func (c *Client) Claims(v interface{}) error {
-	if c.rawDiscoveryClaims == nil {
+	c.mu.RLock()
+	raw := c.rawDiscoveryClaims
+	c.mu.RUnlock()
+
+	if raw == nil {
 		return errors.New("oidc: claims not set")
 	}
-	return json.Unmarshal(c.rawDiscoveryClaims, v)
+	return json.Unmarshal(raw, v)
 }
 
 // UserInfo represents the OpenID Connect userinfo claims.
@@ -175,42 +264,11 @@ func (u *UserInfo) Claims(v interface{}) error {
 	return json.Unmarshal(u.claims, v)
 }
 
-// UserInfo uses the token source to query the provider's user info endpoint.
+// UserInfo uses the token source to query the provider's user info endpoint. Signed responses (application/jwt) are
+// verified with the provider's key set. To decrypt encrypted responses and to ensure the user info belongs to the
+// ID token's subject, use UserInfoForIDToken.
 func (c *Client) UserInfo(ctx context.Context, tokenSource TokenSource) (*UserInfo, error) {
-	if c.discovery.UserInfoURL == "" {
-		return nil, errors.New("oidc: user info endpoint is not supported by this provider")
-	}
-
-	req, err := http.NewRequest("GET", c.discovery.UserInfoURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("oidc: create GET request: %v", err)
-	}
-
-	token, err := tokenSource.OIDCToken(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("oidc: get access token: %v", err)
-	}
-	token.SetAuthHeader(req)
-
-	resp, err := doRequest(ctx, req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("%s: %s", resp.Status, body)
-	}
-
-	var userInfo UserInfo
-	if err := json.Unmarshal(body, &userInfo); err != nil {
-		return nil, fmt.Errorf("oidc: failed to decode userinfo: %v", err)
-	}
-	userInfo.claims = body
-	return &userInfo, nil
+	return c.userInfo(ctx, VerificationConfig{}, tokenSource)
 }
 
 // Verifier returns an IDTokenVerifier that uses the provider's key set to verify JWTs.
@@ -218,28 +276,30 @@ func (c *Client) UserInfo(ctx context.Context, tokenSource TokenSource) (*UserIn
 // The returned IDTokenVerifier is tied to the Client's context and its behavior is
 // undefined once the Client's context is canceled.
 func (c *Client) Verifier(cfg VerificationConfig) *IDTokenVerifier {
-	return newVerifier(c.keySet, cfg, c.issuer)
+	// Verifier uses client's current key set, so it follows key set swaps done by Refresh.
+	return newVerifier(clientKeySet{c: c}, cfg, c.issuer, c.Discovery().IDTokenSigningAlgValuesSupported)
 }
 
 // Revoke revokes provided token. It can be access token or refresh token. In most, revoking access token will
 // revoke refresh token which can be convenient. (IsValid e.g for Google OIDC).
-func (c *Client) Revoke(ctx context.Context, cfg Config, token string) error {
+// Pass "token_type_hint" in extra values (see TokenTypeHintAccessToken and TokenTypeHintRefreshToken) to help the
+// provider find the token.
+func (c *Client) Revoke(ctx context.Context, cfg Config, token string, extra ...url.Values) error {
+	revocationURL := c.Discovery().RevocationURL
+	if revocationURL == "" {
+		return errors.New("oidc: revocation endpoint is not supported by this provider")
+	}
+
 	v := url.Values{}
 	v.Set("token", token)
 
-	req, err := http.NewRequest("POST", c.discovery.RevocationURL, strings.NewReader(v.Encode()))
-	if err != nil {
-		return err
+	for _, e := range extra {
+		for key := range e {
+			v.Set(key, e.Get(key))
+		}
 	}
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.SetBasicAuth(cfg.ClientID, cfg.ClientSecret)
 
-	r, err := doRequest(ctx, req)
-	if err != nil {
-		return err
-	}
-	defer r.Body.Close()
-	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1<<20))
+	r, body, err := c.postForm(ctx, cfg, revocationURL, v)
 	if err != nil {
 		return fmt.Errorf("oidc: cannot revoke token: %v", err)
 	}
@@ -254,10 +314,15 @@ func (c *Client) Revoke(ctx context.Context, cfg Config, token string) error {
 // State is a token to protect the user from CSRF attacks. You must
 // always provide a non-zero string and validate that it matches the
 // the state query parameter on your redirect callback.
+// For PKCE, pass PKCE.AuthCodeParams as extra values.
+// To pass parameters as signed request object, use SignedAuthCodeURL instead.
 // See http://openid.net/specs/openid-connect-core-1_0.html#AuthRequest for more info.
 func (c *Client) AuthCodeURL(cfg Config, state string, extra ...url.Values) string {
-	var buf bytes.Buffer
-	buf.WriteString(c.discovery.AuthURL)
+	return appendQuery(c.Discovery().AuthURL, authCodeParams(cfg, state, extra...))
+}
+
+// authCodeParams returns authorization request parameters for authorization code flow.
+func authCodeParams(cfg Config, state string, extra ...url.Values) url.Values {
 	v := url.Values{
 		"response_type": {ResponseTypeCode},
 		"client_id":     {cfg.ClientID},
@@ -277,8 +342,14 @@ func (c *Client) AuthCodeURL(cfg Config, state string, extra ...url.Values) stri
 			v.Set(key, e.Get(key))
 		}
 	}
+	return v
+}
 
-	if strings.Contains(c.discovery.AuthURL, "?") {
+// appendQuery appends encoded v to the query of given URL.
+func appendQuery(u string, v url.Values) string {
+	var buf bytes.Buffer
+	buf.WriteString(u)
+	if strings.Contains(u, "?") {
 		buf.WriteByte('&')
 	} else {
 		buf.WriteByte('?')
@@ -291,6 +362,7 @@ func (c *Client) AuthCodeURL(cfg Config, state string, extra ...url.Values) stri
 //
 // It is used after a resource provider redirects the user back
 // to the Redirect URI (the URL obtained from AuthCodeURL).
+// If PKCE was used in AuthCodeURL, pass PKCE.ExchangeParams as extra values.
 func (c *Client) Exchange(ctx context.Context, cfg Config, code string, extra ...url.Values) (*Token, error) {
 	v := url.Values{
 		"grant_type":   {GrantTypeAuthCode},
@@ -304,7 +376,7 @@ func (c *Client) Exchange(ctx context.Context, cfg Config, code string, extra ..
 		}
 	}
 
-	return c.token(ctx, cfg.ClientID, cfg.ClientSecret, v)
+	return c.token(ctx, cfg, v)
 }
 
 // Exchange converts an google service account JSON into a token.
@@ -322,7 +394,7 @@ func (c *Client) ExchangeServiceAccount(ctx context.Context, cfg Config, googleS
 		}
 	}
 
-	return c.token(ctx, cfg.ClientID, cfg.ClientSecret, v)
+	return c.token(ctx, cfg, v)
 }
 
 // TokenSource returns a TokenSource that returns t until t expires,
@@ -339,42 +411,117 @@ func (c *Client) TokenSource(cfg Config, t *Token) TokenSource {
 	return src
 }
 
-// token fetches token from OIDC token endpoint with provided URL values.
-func (c *Client) token(ctx context.Context, clientID string, clientSecret string, v url.Values) (*Token, error) {
-	req, err := http.NewRequest("POST", c.discovery.TokenURL, strings.NewReader(v.Encode()))
-	if err != nil {
-		return nil, err
+// postForm sends form values v to the provider endpoint, authenticating the client as configured in cfg.
+// Response body is read (up to 1MB) and closed.
+func (c *Client) postForm(ctx context.Context, cfg Config, endpoint string, v url.Values) (*http.Response, []byte, error) {
+	discovery := c.Discovery()
+	auth := cfg.clientAuth()
+	// Default authentication is not checked, since provider might not advertise the methods it supports.
+	if cfg.ClientAuth != nil && !discovery.SupportsClientAuthMethod(endpoint, auth.Method()) {
+		return nil, nil, fmt.Errorf("oidc: provider does not support %q client authentication method", auth.Method())
+	}
+
+	// DPoP proofs are needed only for token requests. Client assertions for other endpoints are meant for the issuer.
+	dpop := cfg.DPoP
+	audience := discovery.TokenURL
+	if endpoint != discovery.TokenURL {
+		dpop = nil
+		audience = c.issuer.audience(discovery.Issuer, endpoint)
+	}
+
+	httpClient := c.httpClient
+	if mtls, ok := auth.(*TLSClientAuth); ok {
+		endpoint = discovery.mtlsEndpoint(endpoint)
+		var err error
+		httpClient, err = c.mtlsHTTPClient(mtls)
+		if err != nil {
+			return nil, nil, err
+		}
 	}
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.SetBasicAuth(clientID, clientSecret)
 
-	r, err := doRequest(ctx, req)
-	if err != nil {
-		return nil, err
+	// Provider can require DPoP nonce, in which case request is retried once with the nonce it provided.
+	for attempt := 0; ; attempt++ {
+		header := http.Header{}
+		header.Set("Content-Type", "application/x-www-form-urlencoded")
+		header.Set("Accept", "application/json")
+		// Authenticate on every attempt, so client assertions are never reused.
+		if err := auth.AuthenticateRequest(cfg, audience, v, header); err != nil {
+			return nil, nil, fmt.Errorf("oidc: client authentication failed: %v", err)
+		}
+
+		req, err := http.NewRequest("POST", endpoint, strings.NewReader(v.Encode()))
+		if err != nil {
+			return nil, nil, err
+		}
+		req.Header = header
+		if dpop != nil {
+			if err := dpop.setProof(req, ""); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		r, err := doRequestWith(ctx, httpClient, req)
+		if err != nil {
+			return nil, nil, &transientError{err: err}
+		}
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1<<20))
+		r.Body.Close()
+		if err != nil {
+			return nil, nil, &transientError{err: err}
+		}
+
+		if dpop != nil && dpop.observeNonce(req.URL, r.Header) && attempt == 0 && isDPoPNonceError(r, body) {
+			continue
+		}
+		return r, body, nil
 	}
-	defer r.Body.Close()
-	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1<<20))
+}
+
+// transientError is returned if request could not be sent or its response could not be read, e.g. because of network
+// problems. Such requests can be retried.
+type transientError struct {
+	err error
+}
+
+func (e *transientError) Error() string {
+	return e.err.Error()
+}
+
+// token fetches token from OIDC token endpoint with provided URL values.
+func (c *Client) token(ctx context.Context, cfg Config, v url.Values) (*Token, error) {
+	token, _, err := c.tokenWithResponse(ctx, cfg, v)
+	return token, err
+}
+
+// tokenWithResponse fetches token from OIDC token endpoint with provided URL values. It returns parsed token response as well
+// for callers that need non-standard fields.
+func (c *Client) tokenWithResponse(ctx context.Context, cfg Config, v url.Values) (*Token, *TokenResponse, error) {
+	r, body, err := c.postForm(ctx, cfg, c.Discovery().TokenURL, v)
 	if err != nil {
-		return nil, fmt.Errorf("oauth2: cannot fetch token: %v", err)
+		wrapped := fmt.Errorf("oauth2: cannot fetch token: %v", err)
+		if _, ok := err.(*transientError); ok {
+			return nil, nil, &transientError{err: wrapped}
+		}
+		return nil, nil, wrapped
 	}
 	if code := r.StatusCode; code < 200 || code > 299 {
-		return nil, fmt.Errorf("oauth2: cannot fetch token: %v\nResponse: %s", r.Status, body)
+		return nil, nil, newTokenError(r, body)
 	}
 
 	var token *Token
 	content, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
 	if content != "application/json" {
-		return nil, fmt.Errorf("Wrong response content-type. Expected application/json, got %s", content)
+		return nil, nil, fmt.Errorf("Wrong response content-type. Expected application/json, got %s", content)
 	}
 
 	var tr TokenResponse
 	if err = json.Unmarshal(body, &tr); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	var br brokenTokenResponse
 	if err = json.Unmarshal(body, &br); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	token = &Token{
@@ -382,6 +529,13 @@ func (c *Client) token(ctx context.Context, clientID string, clientSecret string
 		RefreshToken: tr.RefreshToken,
 		IDToken:      tr.IDToken,
 	}
+	if strings.EqualFold(tr.TokenType, TokenTypeDPoP) {
+		if cfg.DPoP == nil {
+			return nil, nil, errors.New("oidc: provider issued DPoP-bound token, but DPoP is not configured")
+		}
+		token.TokenType = TokenTypeDPoP
+		token.DPoP = cfg.DPoP
+	}
 
 	token.AccessTokenExpiry = tr.expiry()
 	if token.AccessTokenExpiry.IsZero() {
@@ -391,7 +545,55 @@ func (c *Client) token(ctx context.Context, clientID string, clientSecret string
 	if token.RefreshToken == "" {
 		token.RefreshToken = v.Get("refresh_token")
 	}
-	return token, nil
+	return token, &tr, nil
+}
+
+// Error codes of OAuth2 error responses as defined in RFC 6749 section 5.2 and extensions.
+const (
+	ErrorCodeInvalidRequest       = "invalid_request"
+	ErrorCodeInvalidClient        = "invalid_client"
+	ErrorCodeInvalidGrant         = "invalid_grant"
+	ErrorCodeUnauthorizedClient   = "unauthorized_client"
+	ErrorCodeUnsupportedGrantType = "unsupported_grant_type"
+	ErrorCodeInvalidScope         = "invalid_scope"
+	ErrorCodeAccessDenied         = "access_denied"
+
+	// Device Authorization Grant error codes (RFC 8628 section 3.5).
+	ErrorCodeAuthorizationPending = "authorization_pending"
+	ErrorCodeSlowDown             = "slow_down"
+	ErrorCodeExpiredToken         = "expired_token"
+)
+
+// TokenError is returned when token endpoint responds with non-2xx status. If response is an OAuth2 error response
+// (RFC 6749 section 5.2), Code, Description and URI are filled. Use errors.As to inspect it e.g to tell "invalid_grant"
+// (refresh token expired or revoked) from temporary provider problems.
+type TokenError struct {
+	// Code is an error code from OAuth2 error response e.g "invalid_grant". Empty if response was not in JSON form.
+	Code string `json:"error"`
+	// Description is an optional human-readable description of the error.
+	Description string `json:"error_description,omitempty"`
+	// URI is an optional URI of a web page with information about the error.
+	URI string `json:"error_uri,omitempty"`
+
+	// StatusCode and Status are HTTP status of the response.
+	StatusCode int    `json:"-"`
+	Status     string `json:"-"`
+	// Body is a raw response body.
+	Body []byte `json:"-"`
+}
+
+func newTokenError(r *http.Response, body []byte) *TokenError {
+	e := &TokenError{}
+	// Ignore error, not all responses are OAuth2 compliant.
+	_ = json.Unmarshal(body, e)
+	e.StatusCode = r.StatusCode
+	e.Status = r.Status
+	e.Body = body
+	return e
+}
+
+func (e *TokenError) Error() string {
+	return fmt.Sprintf("oauth2: cannot fetch token: %v\nResponse: %s", e.Status, e.Body)
 }
 
 // TokenResponse is the struct representing the HTTP response from OIDC
@@ -405,6 +607,9 @@ type TokenResponse struct {
 	RefreshToken string         `json:"refresh_token,omitempty"`
 	Scope        string         `json:"scope,omitempty"`
 
+	// IssuedTokenType is returned only for token exchange (RFC 8693).
+	IssuedTokenType string `json:"issued_token_type,omitempty"`
+
 	timeNow func() time.Time
 }
 
@@ -0,0 +1,207 @@
+package oidc
+
+import (
+	"crypto"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+const (
+	// Client authentication methods as registered in token_endpoint_auth_methods_supported.
+	ClientAuthMethodNone          = "none"
+	ClientAuthMethodSecretBasic   = "client_secret_basic"
+	ClientAuthMethodSecretPost    = "client_secret_post"
+	ClientAuthMethodSecretJWT     = "client_secret_jwt"
+	ClientAuthMethodPrivateKeyJWT = "private_key_jwt"
+
+	// ClientAssertionTypeJWTBearer is a client assertion type for JWT client authentication (RFC 7523).
+	ClientAssertionTypeJWTBearer = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+	// DefaultClientAssertionLifetime is a default time after which client assertion expires.
+	DefaultClientAssertionLifetime = 1 * time.Minute
+)
+
+// ClientAuth authenticates the client in requests to provider endpoints that require client authentication
+// e.g token, revocation or introspection endpoint.
+type ClientAuth interface {
+	// Method returns client authentication method name as used in token_endpoint_auth_methods_supported discovery field.
+	Method() string
+	// AuthenticateRequest adds client credentials to form values v or to the header of the request.
+	// Audience is the intended audience of the client assertion: provider's token endpoint for token requests and
+	// provider's issuer for other endpoints (e.g PAR, revocation, introspection or device authorization endpoint).
+	// For multi-tenant providers it is the tenant issuer if exactly one tenant is allowed, otherwise the endpoint itself.
+	AuthenticateRequest(cfg Config, audience string, v url.Values, header http.Header) error
+}
+
+// clientAuth returns configured client authentication. If not configured it is HTTP Basic, even if client secret
+// is empty. Public clients need to opt in to PublicClient explicitly.
+func (c Config) clientAuth() ClientAuth {
+	if c.ClientAuth != nil {
+		return c.ClientAuth
+	}
+	return ClientSecretBasic{}
+}
+
+// PublicClient does not authenticate the client, it only passes client_id form value. Use it for clients that
+// cannot keep client secret (e.g CLIs), ideally together with PKCE. It needs to be set explicitly in Config.ClientAuth,
+// since default is HTTP Basic with empty secret.
+type PublicClient struct{}
+
+// Method returns "none".
+func (PublicClient) Method() string { return ClientAuthMethodNone }
+
+// AuthenticateRequest sets client_id form value.
+func (PublicClient) AuthenticateRequest(cfg Config, _ string, v url.Values, _ http.Header) error {
+	v.Set("client_id", cfg.ClientID)
+	return nil
+}
+
+// ClientSecretBasic authenticates the client using HTTP Basic authentication with client ID and secret.
+type ClientSecretBasic struct{}
+
+// Method returns "client_secret_basic".
+func (ClientSecretBasic) Method() string { return ClientAuthMethodSecretBasic }
+
+// AuthenticateRequest sets HTTP Basic authorization header.
+func (ClientSecretBasic) AuthenticateRequest(cfg Config, _ string, _ url.Values, header http.Header) error {
+	header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(cfg.ClientID+":"+cfg.ClientSecret)))
+	return nil
+}
+
+// ClientSecretPost authenticates the client by passing client ID and secret in the request body.
+type ClientSecretPost struct{}
+
+// Method returns "client_secret_post".
+func (ClientSecretPost) Method() string { return ClientAuthMethodSecretPost }
+
+// AuthenticateRequest sets client_id and client_secret form values.
+func (ClientSecretPost) AuthenticateRequest(cfg Config, _ string, v url.Values, _ http.Header) error {
+	v.Set("client_id", cfg.ClientID)
+	v.Set("client_secret", cfg.ClientSecret)
+	return nil
+}
+
+// PrivateKeyJWT authenticates the client using JWT assertion signed with client's private key (RFC 7523).
+// Provider needs to know the public key e.g from client's registered JWKS.
+type PrivateKeyJWT struct {
+	// Signer signs the assertion. It can be any crypto.Signer with RSA, ECDSA or Ed25519 key e.g backed by HSM or KMS.
+	Signer crypto.Signer
+	// Algorithm is a JWS algorithm. If empty, it is derived from the key type: RS256 for RSA, ES256/ES384/ES512 for ECDSA
+	// depending on the curve and EdDSA for Ed25519.
+	Algorithm jose.SignatureAlgorithm
+	// KeyID is set as "kid" header, so provider can select the right key from client's JWKS.
+	// If empty, RFC 7638 thumbprint of the public key is used.
+	KeyID string
+	// Lifetime of the assertion. Defaults to DefaultClientAssertionLifetime.
+	Lifetime time.Duration
+	// Audience of the assertion. Defaults to provider's token endpoint for token requests and issuer otherwise.
+	Audience string
+}
+
+// Method returns "private_key_jwt".
+func (*PrivateKeyJWT) Method() string { return ClientAuthMethodPrivateKeyJWT }
+
+// AuthenticateRequest signs new client assertion and sets it as client_assertion form value.
+func (a *PrivateKeyJWT) AuthenticateRequest(cfg Config, audience string, v url.Values, _ http.Header) error {
+	signer, err := newCryptoSigner(a.Signer, a.Algorithm, a.KeyID)
+	if err != nil {
+		return err
+	}
+
+	if a.Audience != "" {
+		audience = a.Audience
+	}
+	return setClientAssertion(cfg, jose.SigningKey{Algorithm: signer.alg, Key: signer}, audience, a.Lifetime, v)
+}
+
+// ClientSecretJWT authenticates the client using JWT assertion signed with HMAC using client secret as a key (RFC 7523).
+type ClientSecretJWT struct {
+	// Algorithm is one of HS256, HS384 or HS512. Defaults to HS256.
+	Algorithm jose.SignatureAlgorithm
+	// Lifetime of the assertion. Defaults to DefaultClientAssertionLifetime.
+	Lifetime time.Duration
+	// Audience of the assertion. Defaults to provider's token endpoint for token requests and issuer otherwise.
+	Audience string
+}
+
+// Method returns "client_secret_jwt".
+func (*ClientSecretJWT) Method() string { return ClientAuthMethodSecretJWT }
+
+// AuthenticateRequest signs new client assertion and sets it as client_assertion form value.
+func (a *ClientSecretJWT) AuthenticateRequest(cfg Config, audience string, v url.Values, _ http.Header) error {
+	if cfg.ClientSecret == "" {
+		return errors.New("oidc: client secret is required for client_secret_jwt")
+	}
+
+	alg := a.Algorithm
+	if alg == "" {
+		alg = jose.HS256
+	}
+	switch alg {
+	case jose.HS256, jose.HS384, jose.HS512:
+	default:
+		return fmt.Errorf("oidc: algorithm %s is not supported for client_secret_jwt", alg)
+	}
+
+	if a.Audience != "" {
+		audience = a.Audience
+	}
+	return setClientAssertion(cfg, jose.SigningKey{Algorithm: alg, Key: []byte(cfg.ClientSecret)}, audience, a.Lifetime, v)
+}
+
+// clientAssertionClaims are claims of JWT client assertion as described in RFC 7523 section 3.
+type clientAssertionClaims struct {
+	Issuer   string      `json:"iss"`
+	Subject  string      `json:"sub"`
+	Audience string      `json:"aud"`
+	JTI      string      `json:"jti"`
+	IssuedAt NumericDate `json:"iat"`
+	Expiry   NumericDate `json:"exp"`
+}
+
+func setClientAssertion(cfg Config, key jose.SigningKey, audience string, lifetime time.Duration, v url.Values) error {
+	if lifetime <= 0 {
+		lifetime = DefaultClientAssertionLifetime
+	}
+
+	now := time.Now()
+	payload, err := json.Marshal(clientAssertionClaims{
+		Issuer:   cfg.ClientID,
+		Subject:  cfg.ClientID,
+		Audience: audience,
+		// Unique jti for every assertion, so provider can detect replays.
+		JTI:      randomString(16),
+		IssuedAt: NewNumericDate(now),
+		Expiry:   NewNumericDate(now.Add(lifetime)),
+	})
+	if err != nil {
+		return err
+	}
+
+	signer, err := jose.NewSigner(key, (&jose.SignerOptions{}).WithType("JWT"))
+	if err != nil {
+		return fmt.Errorf("oidc: failed to create client assertion signer: %v", err)
+	}
+
+	jws, err := signer.Sign(payload)
+	if err != nil {
+		return fmt.Errorf("oidc: failed to sign client assertion: %v", err)
+	}
+
+	assertion, err := jws.CompactSerialize()
+	if err != nil {
+		return err
+	}
+
+	v.Set("client_id", cfg.ClientID)
+	v.Set("client_assertion_type", ClientAssertionTypeJWTBearer)
+	v.Set("client_assertion", assertion)
+	return nil
+}
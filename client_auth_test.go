@@ -0,0 +1,150 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/bwplotka/go-httpt/rt"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+func (s *ClientTestSuite) verifyClientAssertion(req *http.Request, key interface{}, expectedKeyID string, expectedAudience string) clientAssertionClaims {
+	s.Require().NoError(req.ParseForm())
+
+	_, _, ok := req.BasicAuth()
+	s.False(ok)
+	s.Equal("client1", req.PostForm.Get("client_id"))
+	s.Equal(ClientAssertionTypeJWTBearer, req.PostForm.Get("client_assertion_type"))
+
+	jws, err := jose.ParseSigned(req.PostForm.Get("client_assertion"))
+	s.Require().NoError(err)
+	s.Require().Len(jws.Signatures, 1)
+	s.Equal(expectedKeyID, jws.Signatures[0].Header.KeyID)
+
+	payload, err := jws.Verify(key)
+	s.Require().NoError(err)
+
+	var claims clientAssertionClaims
+	s.Require().NoError(json.Unmarshal(payload, &claims))
+	s.Equal("client1", claims.Issuer)
+	s.Equal("client1", claims.Subject)
+	s.Equal(expectedAudience, claims.Audience)
+	s.NotEmpty(claims.JTI)
+	s.True(claims.Expiry.Time().After(time.Now()))
+	s.True(claims.Expiry.Time().Sub(claims.IssuedAt.Time()) <= DefaultClientAssertionLifetime)
+	return claims
+}
+
+func (s *ClientTestSuite) TestPrivateKeyJWT_ECDSA() {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	s.Require().NoError(err)
+
+	cfg := Config{
+		ClientID:   "client1",
+		ClientAuth: &PrivateKeyJWT{Signer: key, KeyID: "kid1"},
+	}
+
+	var jtis []string
+	for i := 0; i < 2; i++ {
+		s.s.On("POST", testDiscovery.TokenURL).Push(func(req *http.Request) (*http.Response, error) {
+			claims := s.verifyClientAssertion(req, &key.PublicKey, "kid1", testDiscovery.TokenURL)
+			jtis = append(jtis, claims.JTI)
+			return rt.JSONResponseFunc(http.StatusOK, []byte(`{"access_token": "access1", "token_type": "Bearer", "expires_in": 3600}`))(req)
+		})
+
+		_, err = NewClientCredentialsTokenSource(s.client, cfg, "").OIDCToken(s.testCtx)
+		s.Require().NoError(err)
+	}
+	s.Require().Len(jtis, 2)
+	s.NotEqual(jtis[0], jtis[1])
+
+	s.Equal(0, s.s.Len())
+}
+
+func (s *ClientTestSuite) TestPrivateKeyJWT_RSA_ThumbprintKeyID() {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	s.Require().NoError(err)
+
+	thumbprint, err := (&jose.JSONWebKey{Key: &key.PublicKey}).Thumbprint(crypto.SHA256)
+	s.Require().NoError(err)
+
+	s.s.On("POST", testDiscovery.RevocationURL).Push(func(req *http.Request) (*http.Response, error) {
+		// Assertions for endpoints other than token endpoint are meant for the issuer.
+		s.verifyClientAssertion(req, &key.PublicKey, base64.RawURLEncoding.EncodeToString(thumbprint), exampleIssuer)
+		s.Equal("access1", req.PostForm.Get("token"))
+		return rt.StringResponseFunc(http.StatusOK, "")(req)
+	})
+
+	s.Require().NoError(s.client.Revoke(s.testCtx, Config{
+		ClientID:   "client1",
+		ClientAuth: &PrivateKeyJWT{Signer: key},
+	}, "access1"))
+
+	s.Equal(0, s.s.Len())
+}
+
+func (s *ClientTestSuite) TestClientSecretJWT() {
+	s.s.On("POST", testDiscovery.TokenURL).Push(func(req *http.Request) (*http.Response, error) {
+		s.verifyClientAssertion(req, []byte("secret1"), "", testDiscovery.TokenURL)
+		s.Equal("refresh1", req.PostForm.Get("refresh_token"))
+		return rt.JSONResponseFunc(http.StatusOK, []byte(`{"access_token": "access1", "token_type": "Bearer", "expires_in": 3600}`))(req)
+	})
+
+	_, err := (&TokenRefresher{
+		client: s.client,
+		cfg: Config{
+			ClientID:     "client1",
+			ClientSecret: "secret1",
+			ClientAuth:   &ClientSecretJWT{},
+		},
+		refreshToken: "refresh1",
+	}).OIDCToken(s.testCtx)
+	s.Require().NoError(err)
+
+	s.Equal(0, s.s.Len())
+}
+
+func (s *ClientTestSuite) TestClientSecretJWT_NonHMACAlgorithm() {
+	_, err := NewClientCredentialsTokenSource(s.client, Config{
+		ClientID:     "client1",
+		ClientSecret: "secret1",
+		ClientAuth:   &ClientSecretJWT{Algorithm: jose.RS256},
+	}, "").OIDCToken(s.testCtx)
+	s.Error(err)
+
+	s.Equal(0, s.s.Len())
+}
+
+func (s *ClientTestSuite) TestClientAuth_EmptySecret() {
+	// Default is HTTP Basic even without client secret.
+	s.s.On("POST", testDiscovery.TokenURL).Push(func(req *http.Request) (*http.Response, error) {
+		s.Require().NoError(req.ParseForm())
+		clientID, clientSecret, ok := req.BasicAuth()
+		s.True(ok)
+		s.Equal("client1", clientID)
+		s.Equal("", clientSecret)
+		s.Empty(req.PostForm.Get("client_id"))
+		return rt.JSONResponseFunc(http.StatusOK, []byte(`{"access_token": "access1", "token_type": "Bearer", "expires_in": 3600}`))(req)
+	})
+	_, err := NewClientCredentialsTokenSource(s.client, Config{ClientID: "client1"}, "").OIDCToken(s.testCtx)
+	s.Require().NoError(err)
+
+	s.s.On("POST", testDiscovery.TokenURL).Push(func(req *http.Request) (*http.Response, error) {
+		s.Require().NoError(req.ParseForm())
+		_, _, ok := req.BasicAuth()
+		s.False(ok)
+		s.Equal("client1", req.PostForm.Get("client_id"))
+		return rt.JSONResponseFunc(http.StatusOK, []byte(`{"access_token": "access1", "token_type": "Bearer", "expires_in": 3600}`))(req)
+	})
+	_, err = NewClientCredentialsTokenSource(s.client, Config{ClientID: "client1", ClientAuth: PublicClient{}}, "").OIDCToken(s.testCtx)
+	s.Require().NoError(err)
+
+	s.Equal(0, s.s.Len())
+}
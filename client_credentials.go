@@ -0,0 +1,65 @@
+package oidc
+
+import (
+	"context"
+	"net/url"
+	"strings"
+)
+
+// ClientCredentialsTokenSource is a TokenSource that makes "grant_type"=="client_credentials"
+// HTTP requests to obtain machine tokens for service-to-service calls.
+//
+// Such responses usually do not include ID token, so Verifier returns nil and only access token is validated
+// when used with ReuseTokenSource:
+//
+//	src, _ := oidc.NewReuseTokenSource(nil, oidc.NewClientCredentialsTokenSource(client, cfg, "https://api.example.com"))
+type ClientCredentialsTokenSource struct {
+	client *Client
+	cfg    Config
+
+	audience  string
+	resources []string
+}
+
+// NewClientCredentialsTokenSource constructs client credentials token source. Config scopes are requested as scope parameter.
+// Audience is optional (non-standard, but widely used) audience parameter. Resources are optional resource indicators
+// as defined in RFC 8707.
+func NewClientCredentialsTokenSource(client *Client, cfg Config, audience string, resources ...string) TokenSource {
+	return &ClientCredentialsTokenSource{
+		client:    client,
+		cfg:       cfg,
+		audience:  audience,
+		resources: resources,
+	}
+}
+
+// OIDCToken requests new token from token endpoint using client credentials grant.
+// NOTE: Returned token is not cached. Use ReuseTokenSource for that.
+func (s *ClientCredentialsTokenSource) OIDCToken(ctx context.Context) (*Token, error) {
+	if err := s.client.Discovery().checkGrantType(GrantTypeClientCredentials); err != nil {
+		return nil, err
+	}
+
+	v := url.Values{
+		"grant_type": {GrantTypeClientCredentials},
+	}
+
+	if len(s.cfg.Scopes) > 0 {
+		v.Set("scope", strings.Join(s.cfg.Scopes, " "))
+	}
+
+	if s.audience != "" {
+		v.Set("audience", s.audience)
+	}
+
+	for _, r := range s.resources {
+		v.Add("resource", r)
+	}
+
+	return s.client.token(ctx, s.cfg, v)
+}
+
+// Verifier returns nil, since client credentials grant does not issue ID tokens.
+func (s *ClientCredentialsTokenSource) Verifier() Verifier {
+	return nil
+}
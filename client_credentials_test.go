@@ -0,0 +1,42 @@
+package oidc
+
+import (
+	"net/http"
+
+	"github.com/bwplotka/go-httpt/rt"
+)
+
+func (s *ClientTestSuite) TestClientCredentialsTokenSource_Reuse() {
+	s.s.On("POST", testDiscovery.TokenURL).Push(func(req *http.Request) (*http.Response, error) {
+		s.Require().NoError(req.ParseForm())
+		s.Equal(GrantTypeClientCredentials, req.PostForm.Get("grant_type"))
+		s.Equal("read write", req.PostForm.Get("scope"))
+		s.Equal("aud1", req.PostForm.Get("audience"))
+		s.Equal([]string{"https://r1.org", "https://r2.org"}, req.PostForm["resource"])
+
+		clientID, clientSecret, ok := req.BasicAuth()
+		s.True(ok)
+		s.Equal("client1", clientID)
+		s.Equal("secret1", clientSecret)
+
+		return rt.JSONResponseFunc(http.StatusOK, []byte(`{"access_token": "access1", "token_type": "Bearer", "expires_in": 3600}`))(req)
+	})
+
+	src, _ := NewReuseTokenSource(nil, NewClientCredentialsTokenSource(s.client, Config{
+		ClientID:     "client1",
+		ClientSecret: "secret1",
+		Scopes:       []string{"read", "write"},
+	}, "aud1", "https://r1.org", "https://r2.org"))
+
+	token, err := src.OIDCToken(s.testCtx)
+	s.Require().NoError(err)
+	s.Equal("access1", token.AccessToken)
+	s.Empty(token.IDToken)
+
+	// Token without ID token should be reused until access token expires.
+	token2, err := src.OIDCToken(s.testCtx)
+	s.Require().NoError(err)
+	s.Equal(token, token2)
+
+	s.Equal(0, s.s.Len())
+}
@@ -2,6 +2,7 @@ package oidc
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"testing"
 	"time"
@@ -60,6 +61,18 @@ func (s *ClientTestSuite) SetupTest() {
 	s.s.Reset()
 }
 
+// clientWithDiscovery returns copy of the test client with modified discovery.
+func (s *ClientTestSuite) clientWithDiscovery(modify func(d *DiscoveryJSON)) *Client {
+	d := s.client.Discovery()
+	modify(&d)
+	return &Client{
+		issuer:     s.client.issuer,
+		httpClient: s.client.httpClient,
+		discovery:  d,
+		keySet:     s.client.keySet,
+	}
+}
+
 func TestClientTestSuite(t *testing.T) {
 	suite.Run(t, &ClientTestSuite{})
 }
@@ -92,3 +105,29 @@ func TestTokenResponseSetExpiry(t *testing.T) {
 	assert.Equal(t, expiresIn, int(tr.ExpiresIn))
 	assert.Equal(t, expiry, tr.expiry())
 }
+
+func (s *ClientTestSuite) TestTokenError() {
+	s.s.On("POST", testDiscovery.TokenURL).Push(rt.JSONResponseFunc(http.StatusBadRequest, []byte(
+		`{"error": "invalid_grant", "error_description": "refresh token revoked", "error_uri": "https://example.com/errors"}`,
+	)))
+
+	_, err := NewTokenRefresher(s.client, Config{ClientID: "client1", ClientSecret: "secret1"}, "refresh1").OIDCToken(s.testCtx)
+	s.Require().Error(err)
+
+	var tErr *TokenError
+	s.Require().True(errors.As(err, &tErr))
+	s.Equal(ErrorCodeInvalidGrant, tErr.Code)
+	s.Equal("refresh token revoked", tErr.Description)
+	s.Equal("https://example.com/errors", tErr.URI)
+	s.Equal(http.StatusBadRequest, tErr.StatusCode)
+
+	s.s.On("POST", testDiscovery.TokenURL).Push(rt.StringResponseFunc(http.StatusServiceUnavailable, "down"))
+
+	_, err = NewTokenRefresher(s.client, Config{ClientID: "client1", ClientSecret: "secret1"}, "refresh1").OIDCToken(s.testCtx)
+	s.Require().True(errors.As(err, &tErr))
+	s.Empty(tErr.Code)
+	s.Equal(http.StatusServiceUnavailable, tErr.StatusCode)
+	s.Equal("down", string(tErr.Body))
+
+	s.Equal(0, s.s.Len())
+}
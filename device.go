@@ -0,0 +1,154 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultDevicePollInterval is a polling interval used when provider does not specify one. See RFC 8628 section 3.2.
+	defaultDevicePollInterval = 5 * time.Second
+	// slowDownIncrease is an amount of time the polling interval is increased on every "slow_down" response.
+	slowDownIncrease = 5 * time.Second
+)
+
+// devicePollWait waits for the polling interval. Replaced in tests.
+var devicePollWait = time.After
+
+// DeviceAuthResponse is a Device Authorization Response as described in RFC 8628 section 3.2.
+type DeviceAuthResponse struct {
+	// DeviceCode is used by the client to poll token endpoint.
+	DeviceCode string `json:"device_code"`
+	// UserCode is a code that user needs to enter on the verification page.
+	UserCode string `json:"user_code"`
+	// VerificationURI is a page on provider side where user can enter the UserCode.
+	VerificationURI string `json:"verification_uri"`
+	// VerificationURIComplete is an optional verification page that includes UserCode already.
+	VerificationURIComplete string `json:"verification_uri_complete,omitempty"`
+
+	ExpiresIn expirationTime `json:"expires_in"`
+	Interval  expirationTime `json:"interval,omitempty"`
+}
+
+// brokenDeviceAuthResponse represents response that is not compliant with RFC 8628.
+type brokenDeviceAuthResponse struct {
+	VerificationURL string `json:"verification_url"` // Google spelling of verification_uri.
+}
+
+// DeviceAuth starts Device Authorization Grant (RFC 8628). Returned user code and verification URI needs to be
+// presented to the user, who then authorizes the request on any other device that has a browser.
+// Use ExchangeDeviceCode to obtain the token once the user finishes.
+func (c *Client) DeviceAuth(ctx context.Context, cfg Config, extra ...url.Values) (*DeviceAuthResponse, error) {
+	if c.Discovery().DeviceAuthorizationURL == "" {
+		return nil, errors.New("oidc: device authorization endpoint is not supported by this provider")
+	}
+	if err := c.Discovery().checkGrantType(GrantTypeDeviceCode); err != nil {
+		return nil, err
+	}
+
+	v := url.Values{
+		"client_id": {cfg.ClientID},
+	}
+	if len(cfg.Scopes) > 0 {
+		v.Set("scope", strings.Join(cfg.Scopes, " "))
+	}
+
+	for _, e := range extra {
+		for key := range e {
+			v.Set(key, e.Get(key))
+		}
+	}
+
+	r, body, err := c.postForm(ctx, cfg, c.Discovery().DeviceAuthorizationURL, v)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: cannot start device authorization: %v", err)
+	}
+	if code := r.StatusCode; code < 200 || code > 299 {
+		return nil, fmt.Errorf("oidc: cannot start device authorization: %v\nResponse: %s", r.Status, body)
+	}
+
+	var resp DeviceAuthResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("oidc: failed to decode device authorization response: %v", err)
+	}
+
+	if resp.VerificationURI == "" {
+		var br brokenDeviceAuthResponse
+		if err := json.Unmarshal(body, &br); err != nil {
+			return nil, fmt.Errorf("oidc: failed to decode device authorization response: %v", err)
+		}
+		resp.VerificationURI = br.VerificationURL
+	}
+
+	if resp.DeviceCode == "" || resp.UserCode == "" || resp.VerificationURI == "" {
+		return nil, fmt.Errorf("oidc: device authorization response is missing required fields. Response: %s", body)
+	}
+	return &resp, nil
+}
+
+// ExchangeDeviceCode polls token endpoint until the user authorizes (or denies) the device authorization request
+// started by DeviceAuth. It honours polling interval, "authorization_pending" and "slow_down" responses.
+// Requests that fail because of network problems are retried on the next poll.
+// It blocks until the token is issued, device code expires or ctx is done.
+func (c *Client) ExchangeDeviceCode(ctx context.Context, cfg Config, deviceAuth *DeviceAuthResponse, extra ...url.Values) (*Token, error) {
+	v := url.Values{
+		"grant_type":  {GrantTypeDeviceCode},
+		"device_code": {deviceAuth.DeviceCode},
+		"client_id":   {cfg.ClientID},
+	}
+
+	for _, e := range extra {
+		for key := range e {
+			v.Set(key, e.Get(key))
+		}
+	}
+
+	interval := defaultDevicePollInterval
+	if deviceAuth.Interval > 0 {
+		interval = time.Duration(deviceAuth.Interval) * time.Second
+	}
+
+	var expired <-chan time.Time
+	if deviceAuth.ExpiresIn > 0 {
+		expiryTimer := time.NewTimer(time.Duration(deviceAuth.ExpiresIn) * time.Second)
+		defer expiryTimer.Stop()
+		expired = expiryTimer.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-expired:
+			return nil, errors.New("oidc: device code expired before user authorized the request")
+		case <-devicePollWait(interval):
+		}
+
+		token, err := c.token(ctx, cfg, v)
+		if err == nil {
+			return token, nil
+		}
+
+		var tErr *TokenError
+		if !errors.As(err, &tErr) {
+			var transient *transientError
+			if errors.As(err, &transient) {
+				continue
+			}
+			return nil, err
+		}
+
+		switch tErr.Code {
+		case ErrorCodeAuthorizationPending:
+		case ErrorCodeSlowDown:
+			interval += slowDownIncrease
+		default:
+			return nil, err
+		}
+	}
+}
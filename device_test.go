@@ -0,0 +1,126 @@
+package oidc
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/bwplotka/go-httpt/rt"
+)
+
+// stubDevicePollWait records polling intervals and returns channel that fires immediately (or never, if block is true).
+func stubDevicePollWait(block bool) (intervals *[]time.Duration, restore func()) {
+	intervals = &[]time.Duration{}
+	old := devicePollWait
+	devicePollWait = func(d time.Duration) <-chan time.Time {
+		*intervals = append(*intervals, d)
+		c := make(chan time.Time, 1)
+		if !block {
+			c <- time.Now()
+		}
+		return c
+	}
+	return intervals, func() { devicePollWait = old }
+}
+
+func (s *ClientTestSuite) pushDeviceTokenError(code string) {
+	s.s.On("POST", testDiscovery.TokenURL).Push(func(req *http.Request) (*http.Response, error) {
+		s.Require().NoError(req.ParseForm())
+		s.Equal(GrantTypeDeviceCode, req.PostForm.Get("grant_type"))
+		s.Equal("device1", req.PostForm.Get("device_code"))
+		return rt.JSONResponseFunc(http.StatusBadRequest, []byte(`{"error": "`+code+`"}`))(req)
+	})
+}
+
+func (s *ClientTestSuite) TestExchangeDeviceCode_SlowDown() {
+	intervals, restore := stubDevicePollWait(false)
+	defer restore()
+
+	s.pushDeviceTokenError(ErrorCodeAuthorizationPending)
+	s.pushDeviceTokenError(ErrorCodeSlowDown)
+	s.pushDeviceTokenError(ErrorCodeAuthorizationPending)
+	s.s.On("POST", testDiscovery.TokenURL).Push(
+		rt.JSONResponseFunc(http.StatusOK, []byte(`{"access_token": "access1", "token_type": "Bearer", "expires_in": 3600}`)),
+	)
+
+	token, err := s.client.ExchangeDeviceCode(s.testCtx, Config{ClientID: "client1"}, &DeviceAuthResponse{
+		DeviceCode: "device1",
+		ExpiresIn:  600,
+		Interval:   2,
+	})
+	s.Require().NoError(err)
+	s.Equal("access1", token.AccessToken)
+
+	// Interval is increased by 5 seconds on slow_down and stays increased.
+	s.Equal([]time.Duration{2 * time.Second, 2 * time.Second, 7 * time.Second, 7 * time.Second}, *intervals)
+	s.Equal(0, s.s.Len())
+}
+
+func (s *ClientTestSuite) TestExchangeDeviceCode_TransportErr_Retried() {
+	intervals, restore := stubDevicePollWait(false)
+	defer restore()
+
+	s.s.On("POST", testDiscovery.TokenURL).Push(func(*http.Request) (*http.Response, error) {
+		return nil, errors.New("connection reset by peer")
+	})
+	s.pushDeviceTokenError(ErrorCodeAuthorizationPending)
+	s.s.On("POST", testDiscovery.TokenURL).Push(
+		rt.JSONResponseFunc(http.StatusOK, []byte(`{"access_token": "access1", "token_type": "Bearer", "expires_in": 3600}`)),
+	)
+
+	token, err := s.client.ExchangeDeviceCode(s.testCtx, Config{ClientID: "client1"}, &DeviceAuthResponse{
+		DeviceCode: "device1",
+		ExpiresIn:  600,
+		Interval:   2,
+	})
+	s.Require().NoError(err)
+	s.Equal("access1", token.AccessToken)
+	s.Equal([]time.Duration{2 * time.Second, 2 * time.Second, 2 * time.Second}, *intervals)
+	s.Equal(0, s.s.Len())
+}
+
+func (s *ClientTestSuite) TestExchangeDeviceCode_DefaultInterval() {
+	intervals, restore := stubDevicePollWait(false)
+	defer restore()
+
+	s.s.On("POST", testDiscovery.TokenURL).Push(
+		rt.JSONResponseFunc(http.StatusOK, []byte(`{"access_token": "access1", "token_type": "Bearer", "expires_in": 3600}`)),
+	)
+
+	_, err := s.client.ExchangeDeviceCode(s.testCtx, Config{ClientID: "client1"}, &DeviceAuthResponse{DeviceCode: "device1"})
+	s.Require().NoError(err)
+	s.Equal([]time.Duration{defaultDevicePollInterval}, *intervals)
+}
+
+func (s *ClientTestSuite) TestExchangeDeviceCode_ExpiredToken_Err() {
+	_, restore := stubDevicePollWait(false)
+	defer restore()
+
+	s.pushDeviceTokenError(ErrorCodeAuthorizationPending)
+	s.pushDeviceTokenError(ErrorCodeExpiredToken)
+
+	_, err := s.client.ExchangeDeviceCode(s.testCtx, Config{ClientID: "client1"}, &DeviceAuthResponse{
+		DeviceCode: "device1",
+		ExpiresIn:  600,
+	})
+	s.Require().Error(err)
+	var tErr *TokenError
+	s.Require().True(errors.As(err, &tErr), "expected *TokenError, got %T", err)
+	s.Equal(ErrorCodeExpiredToken, tErr.Code)
+	s.Equal(0, s.s.Len())
+}
+
+func (s *ClientTestSuite) TestExchangeDeviceCode_DeviceCodeExpired_Err() {
+	// Polling never fires, so only the device code expiry can end the exchange.
+	_, restore := stubDevicePollWait(true)
+	defer restore()
+
+	start := time.Now()
+	_, err := s.client.ExchangeDeviceCode(s.testCtx, Config{ClientID: "client1"}, &DeviceAuthResponse{
+		DeviceCode: "device1",
+		ExpiresIn:  1,
+	})
+	s.Require().Error(err)
+	s.Equal("oidc: device code expired before user authorized the request", err.Error())
+	s.True(time.Since(start) >= 1*time.Second)
+}
@@ -0,0 +1,228 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/square/go-jose.v2"
+)
+
+// supportedOrNotAdvertised returns true if v is in the values or provider does not advertise any values at all.
+// Many providers omit optional metadata even though they support the feature, so lack of metadata is not
+// treated as lack of support.
+func supportedOrNotAdvertised(values []string, v string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, s := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// SupportsGrantType returns false if provider advertises grant types and given one is not among them.
+func (d DiscoveryJSON) SupportsGrantType(grantType string) bool {
+	return supportedOrNotAdvertised(d.GrantTypesSupported, grantType)
+}
+
+// SupportsResponseType returns false if provider advertises response types and given one is not among them.
+func (d DiscoveryJSON) SupportsResponseType(responseType string) bool {
+	return supportedOrNotAdvertised(d.ResponseTypesSupported, responseType)
+}
+
+// SupportsResponseMode returns false if provider advertises response modes and given one is not among them.
+func (d DiscoveryJSON) SupportsResponseMode(responseMode string) bool {
+	return supportedOrNotAdvertised(d.ResponseModesSupported, responseMode)
+}
+
+// SupportsScope returns false if provider advertises scopes and given one is not among them.
+func (d DiscoveryJSON) SupportsScope(scope string) bool {
+	return supportedOrNotAdvertised(d.ScopesSupported, scope)
+}
+
+// SupportsCodeChallengeMethod returns false if provider advertises PKCE methods and given one is not among them.
+func (d DiscoveryJSON) SupportsCodeChallengeMethod(method string) bool {
+	return supportedOrNotAdvertised(d.CodeChallengeMethodsSupported, method)
+}
+
+// SupportsClientAuthMethod returns false if provider advertises client authentication methods for given endpoint and
+// given method is not among them. Revocation and introspection endpoints fall back to token endpoint methods.
+func (d DiscoveryJSON) SupportsClientAuthMethod(endpoint string, method string) bool {
+	methods := d.TokenEndpointAuthMethodsSupported
+	switch {
+	case endpoint == d.RevocationURL && len(d.RevocationEndpointAuthMethodsSupported) > 0:
+		methods = d.RevocationEndpointAuthMethodsSupported
+	case endpoint == d.IntrospectionURL && len(d.IntrospectionEndpointAuthMethodsSupported) > 0:
+		methods = d.IntrospectionEndpointAuthMethodsSupported
+	}
+	return supportedOrNotAdvertised(methods, method)
+}
+
+// checkGrantType returns error if provider does not support given grant type.
+func (d DiscoveryJSON) checkGrantType(grantType string) error {
+	if !d.SupportsGrantType(grantType) {
+		return fmt.Errorf("oidc: provider does not support %q grant type. Supported: %v", grantType, d.GrantTypesSupported)
+	}
+	return nil
+}
+
+type discoveryResult struct {
+	metadata DiscoveryJSON
+	raw      []byte
+
+	fetched time.Time
+	// expiry is derived from response cache headers. Zero if none were returned.
+	expiry time.Time
+}
+
+func (c *Client) fetchDiscovery(ctx context.Context) (*discoveryResult, error) {
+	wellKnown := strings.TrimSuffix(c.issuer.issuer, "/") + DiscoveryEndpoint
+	req, err := http.NewRequest("GET", wellKnown, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.doRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", resp.Status, body)
+	}
+	var p DiscoveryJSON
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, fmt.Errorf("oidc: failed to decode provider discovery object: %v", err)
+	}
+	if err := c.issuer.validateDiscovery(p.Issuer); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	return &discoveryResult{
+		metadata: p,
+		raw:      body,
+		fetched:  now,
+		expiry:   cacheExpiry(resp.Header, now),
+	}, nil
+}
+
+// cacheExpiry returns time until which response can be cached according to Cache-Control max-age (minus Age) or
+// Expires headers. "no-cache" and "no-store" directives mean response is stale immediately.
+// Zero time is returned if there are no cache headers.
+func cacheExpiry(h http.Header, now time.Time) time.Time {
+	if cc := h.Get("Cache-Control"); cc != "" {
+		for _, directive := range strings.Split(cc, ",") {
+			directive = strings.ToLower(strings.TrimSpace(directive))
+			switch {
+			case directive == "no-cache" || directive == "no-store":
+				return now
+			case strings.HasPrefix(directive, "max-age="):
+				maxAge, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age="))
+				if err != nil {
+					continue
+				}
+				if age, err := strconv.Atoi(h.Get("Age")); err == nil {
+					maxAge -= age
+				}
+				return now.Add(time.Duration(maxAge) * time.Second)
+			}
+		}
+	}
+
+	if expires := h.Get("Expires"); expires != "" {
+		t, err := http.ParseTime(expires)
+		if err != nil {
+			// Invalid Expires header means already expired (RFC 7234 section 5.3).
+			return now
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// Refresh fetches discovery document again and atomically swaps provider metadata. If jwks_uri changed, key set
+// is swapped as well; verifiers created by the Client use the new one immediately. On error, previous metadata is kept.
+// Refresh ignores cache headers; see RefreshPeriodically for that.
+func (c *Client) Refresh(ctx context.Context) error {
+	d, err := c.fetchDiscovery(ctx)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.lastRefreshErr = err
+	if err != nil {
+		return fmt.Errorf("oidc: failed to refresh discovery: %v", err)
+	}
+
+	if d.metadata.JWKSURL != c.discovery.JWKSURL {
+		c.keySet = c.newKeySet(d.metadata.JWKSURL)
+	}
+	c.discovery = d.metadata
+	c.rawDiscoveryClaims = d.raw
+	c.lastRefresh = d.fetched
+	c.discoveryExpiry = d.expiry
+	return nil
+}
+
+// RefreshPeriodically refreshes discovery document in a loop until ctx is done. If provider returned cache headers,
+// refresh happens when the cached document expires, but not more often than interval. Otherwise it happens every interval.
+// Errors are not returned; use LastRefresh to check them. It blocks, so run it in a separate goroutine:
+//
+//	go client.RefreshPeriodically(ctx, 1*time.Hour)
+func (c *Client) RefreshPeriodically(ctx context.Context, interval time.Duration) {
+	for {
+		wait := interval
+		c.mu.RLock()
+		if !c.discoveryExpiry.IsZero() && c.lastRefreshErr == nil {
+			if untilExpiry := time.Until(c.discoveryExpiry); untilExpiry > wait {
+				wait = untilExpiry
+			}
+		}
+		c.mu.RUnlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+
+		_ = c.Refresh(ctx)
+	}
+}
+
+// LastRefresh returns time of the last successful discovery fetch (including the initial one done by NewClient)
+// and error of the last Refresh attempt, which is nil if it succeeded.
+func (c *Client) LastRefresh() (time.Time, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.lastRefresh, c.lastRefreshErr
+}
+
+// newKeySet creates cached key set for given JWKS URL that uses Client's HTTP client.
+func (c *Client) newKeySet(jwksURL string) keySet {
+	return newCachedKeySet(newRemoteKeySet(jwksURL, c.doRequest), DefaultKeySetExpiration, time.Now)
+}
+
+// clientKeySet delegates to current key set of the Client, so it can be swapped on Refresh.
+type clientKeySet struct {
+	c *Client
+}
+
+func (k clientKeySet) Keys(ctx context.Context) ([]jose.JSONWebKey, error) {
+	k.c.mu.RLock()
+	keySet := k.c.keySet
+	k.c.mu.RUnlock()
+	return keySet.Keys(ctx)
+}
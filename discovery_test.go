@@ -0,0 +1,142 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bwplotka/go-httpt"
+	"github.com/bwplotka/go-httpt/rt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiscoveryJSON_Unmarshal(t *testing.T) {
+	var d DiscoveryJSON
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"issuer": "https://issuer.org",
+		"authorization_endpoint": "https://issuer.org/auth",
+		"token_endpoint": "https://issuer.org/token",
+		"end_session_endpoint": "https://issuer.org/logout",
+		"registration_endpoint": "https://issuer.org/register",
+		"pushed_authorization_request_endpoint": "https://issuer.org/par",
+		"response_types_supported": ["code", "id_token"],
+		"grant_types_supported": ["authorization_code", "refresh_token"],
+		"code_challenge_methods_supported": ["S256"],
+		"token_endpoint_auth_methods_supported": ["private_key_jwt"],
+		"id_token_signing_alg_values_supported": ["RS256", "ES256"],
+		"request_uri_parameter_supported": false,
+		"backchannel_logout_supported": true,
+		"mtls_endpoint_aliases": {"token_endpoint": "https://mtls.issuer.org/token"}
+	}`), &d))
+
+	assert.Equal(t, "https://issuer.org/logout", d.EndSessionURL)
+	assert.Equal(t, "https://issuer.org/register", d.RegistrationURL)
+	assert.Equal(t, "https://issuer.org/par", d.PushedAuthorizationRequestURL)
+	assert.Equal(t, []string{"RS256", "ES256"}, d.IDTokenSigningAlgValuesSupported)
+	require.NotNil(t, d.RequestURIParameterSupported)
+	assert.False(t, *d.RequestURIParameterSupported)
+	assert.True(t, d.BackchannelLogoutSupported)
+	assert.Equal(t, "https://mtls.issuer.org/token", d.mtlsEndpoint(d.TokenURL))
+
+	assert.True(t, d.SupportsResponseType("code"))
+	assert.False(t, d.SupportsResponseType("code id_token"))
+	assert.True(t, d.SupportsGrantType(GrantTypeRefreshToken))
+	assert.False(t, d.SupportsGrantType(GrantTypeClientCredentials))
+	assert.True(t, d.SupportsCodeChallengeMethod(CodeChallengeMethodS256))
+	assert.False(t, d.SupportsCodeChallengeMethod(CodeChallengeMethodPlain))
+	assert.True(t, d.SupportsClientAuthMethod(d.TokenURL, ClientAuthMethodPrivateKeyJWT))
+	assert.False(t, d.SupportsClientAuthMethod(d.TokenURL, ClientAuthMethodSecretPost))
+
+	// Not advertised metadata does not mean lack of support.
+	assert.True(t, d.SupportsScope("openid"))
+	assert.True(t, d.SupportsResponseMode("form_post"))
+}
+
+func (s *ClientTestSuite) TestCapabilityChecks_FailFast() {
+	client := s.clientWithDiscovery(func(d *DiscoveryJSON) {
+		d.GrantTypesSupported = []string{GrantTypeAuthCode, GrantTypeRefreshToken}
+		d.TokenEndpointAuthMethodsSupported = []string{ClientAuthMethodPrivateKeyJWT}
+	})
+
+	_, err := NewClientCredentialsTokenSource(client, Config{ClientID: "client1", ClientSecret: "secret1"}, "").OIDCToken(s.testCtx)
+	s.Error(err)
+
+	_, _, err = client.ExchangeToken(s.testCtx, Config{ClientID: "client1"}, TokenExchangeRequest{SubjectToken: "token1"})
+	s.Error(err)
+
+	_, err = NewTokenRefresher(client, Config{ClientID: "client1", ClientSecret: "secret1", ClientAuth: ClientSecretPost{}}, "refresh1").OIDCToken(s.testCtx)
+	s.Error(err)
+
+	// No request should reach the provider.
+	s.Equal(0, s.s.Len())
+}
+
+func TestCacheExpiry(t *testing.T) {
+	now := time.Unix(1000, 0)
+
+	assert.Equal(t, time.Time{}, cacheExpiry(http.Header{}, now))
+	assert.Equal(t, now.Add(60*time.Second), cacheExpiry(http.Header{"Cache-Control": {"public, max-age=60"}}, now))
+	assert.Equal(t, now.Add(50*time.Second), cacheExpiry(http.Header{"Cache-Control": {"max-age=60"}, "Age": {"10"}}, now))
+	assert.Equal(t, now, cacheExpiry(http.Header{"Cache-Control": {"no-cache"}}, now))
+	assert.Equal(t, now, cacheExpiry(http.Header{"Expires": {"0"}}, now))
+	assert.Equal(t,
+		time.Date(2015, 10, 21, 7, 28, 0, 0, time.UTC),
+		cacheExpiry(http.Header{"Expires": {"Wed, 21 Oct 2015 07:28:00 GMT"}}, now),
+	)
+}
+
+func TestClient_Refresh(t *testing.T) {
+	s := httpt.NewServer(t)
+	ctx := context.WithValue(context.TODO(), HTTPClientCtxKey, s.HTTPClient())
+
+	discoveryResponse := func(d DiscoveryJSON, header http.Header) func(*http.Request) (*http.Response, error) {
+		return func(req *http.Request) (*http.Response, error) {
+			b, err := json.Marshal(d)
+			require.NoError(t, err)
+			resp, err := rt.JSONResponseFunc(http.StatusOK, b)(req)
+			for k, v := range header {
+				resp.Header[k] = v
+			}
+			return resp, err
+		}
+	}
+
+	s.On("GET", exampleIssuer+DiscoveryEndpoint).Push(discoveryResponse(testDiscovery, http.Header{"Cache-Control": {"max-age=3600"}}))
+	client, err := NewClient(ctx, exampleIssuer)
+	require.NoError(t, err)
+
+	lastRefresh, err := client.LastRefresh()
+	require.NoError(t, err)
+	assert.False(t, lastRefresh.IsZero())
+	assert.True(t, client.discoveryExpiry.After(time.Now().Add(59*time.Minute)))
+
+	// Verifier created before the refresh should use new key set.
+	verifier := client.Verifier(VerificationConfig{ClientID: "client1"})
+
+	moved := testDiscovery
+	moved.TokenURL = exampleIssuer + "/v2/token"
+	moved.JWKSURL = exampleIssuer + "/v2/jwks"
+	s.On("GET", exampleIssuer+DiscoveryEndpoint).Push(discoveryResponse(moved, nil))
+	require.NoError(t, client.Refresh(ctx))
+
+	assert.Equal(t, moved.TokenURL, client.Discovery().TokenURL)
+	assert.True(t, client.discoveryExpiry.IsZero())
+
+	s.On("GET", moved.JWKSURL).Push(rt.JSONResponseFunc(http.StatusOK, []byte(`{"keys": []}`)))
+	_, err = verifier.keySet.Keys(ctx)
+	require.NoError(t, err)
+
+	// Failed refresh keeps previous metadata.
+	s.On("GET", exampleIssuer+DiscoveryEndpoint).Push(rt.StringResponseFunc(http.StatusServiceUnavailable, ""))
+	require.Error(t, client.Refresh(ctx))
+	assert.Equal(t, moved.TokenURL, client.Discovery().TokenURL)
+
+	lastRefresh2, err := client.LastRefresh()
+	require.Error(t, err)
+	assert.False(t, lastRefresh2.Before(lastRefresh))
+
+	require.Equal(t, 0, s.Len())
+}
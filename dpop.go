@@ -0,0 +1,301 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+const (
+	// DPoPHeader is a header carrying DPoP proof (RFC 9449).
+	DPoPHeader = "DPoP"
+	// DPoPNonceHeader is a header in which servers provide nonce that needs to be included in next DPoP proofs.
+	DPoPNonceHeader = "DPoP-Nonce"
+	// TokenTypeDPoP is a token type of DPoP-bound access tokens. Such tokens are sent with "DPoP" authorization scheme.
+	TokenTypeDPoP = "DPoP"
+	// DPoPProofType is a "typ" header of DPoP proofs.
+	DPoPProofType = "dpop+jwt"
+
+	// ErrorCodeUseDPoPNonce is returned by servers that require DPoP-Nonce in the proof.
+	ErrorCodeUseDPoPNonce = "use_dpop_nonce"
+)
+
+// DPoPProver creates DPoP proofs (RFC 9449) that prove possession of the private key tokens are bound to.
+// It remembers nonces provided by servers (DPoP-Nonce header) per origin and includes them in next proofs.
+// It is safe for concurrent use.
+type DPoPProver struct {
+	signer *cryptoSigner
+
+	mu     sync.Mutex
+	nonces map[string]string
+}
+
+// NewDPoPProver constructs DPoPProver with given key. Use persisted key (e.g from disk, HSM or KMS) if tokens bound to it
+// need to outlive the process. If alg is empty, it is derived from the key type.
+func NewDPoPProver(signer crypto.Signer, alg jose.SignatureAlgorithm) (*DPoPProver, error) {
+	s, err := newCryptoSigner(signer, alg, "")
+	if err != nil {
+		return nil, err
+	}
+	return &DPoPProver{signer: s, nonces: map[string]string{}}, nil
+}
+
+// NewEphemeralDPoPProver constructs DPoPProver with new P-256 key that lives only in memory. Tokens bound to it
+// cannot be used after the process exits.
+func NewEphemeralDPoPProver() (*DPoPProver, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: failed to generate DPoP key: %v", err)
+	}
+	return NewDPoPProver(key, jose.ES256)
+}
+
+// Thumbprint returns base64url encoded RFC 7638 SHA-256 thumbprint of the public key as used in "cnf.jkt" claim and
+// "dpop_jkt" authorization request parameter.
+func (p *DPoPProver) Thumbprint() string {
+	// cryptoSigner uses the thumbprint as key ID if none is given.
+	return p.signer.jwk.KeyID
+}
+
+// dpopProofClaims are claims of DPoP proof JWT as described in RFC 9449 section 4.2.
+type dpopProofClaims struct {
+	JTI      string      `json:"jti"`
+	Method   string      `json:"htm"`
+	URL      string      `json:"htu"`
+	IssuedAt NumericDate `json:"iat"`
+	// AccessTokenHash is base64url encoded SHA-256 hash of the access token. Required for resource requests.
+	AccessTokenHash string `json:"ath,omitempty"`
+	Nonce           string `json:"nonce,omitempty"`
+}
+
+// Proof returns new DPoP proof for the request with given method and URL. Access token should be passed for requests
+// to resource servers, it is empty for token requests.
+func (p *DPoPProver) Proof(method string, targetURL string, accessToken string) (string, error) {
+	u, err := url.Parse(targetURL)
+	if err != nil {
+		return "", fmt.Errorf("oidc: failed to parse DPoP target URL: %v", err)
+	}
+
+	claims := dpopProofClaims{
+		JTI:      randomString(16),
+		Method:   method,
+		URL:      dpopHTU(u),
+		IssuedAt: NewNumericDate(time.Now()),
+		Nonce:    p.nonce(u),
+	}
+	if accessToken != "" {
+		claims.AccessTokenHash = AccessTokenHash(accessToken)
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: p.signer.alg, Key: p.signer},
+		(&jose.SignerOptions{EmbedJWK: true}).WithType(DPoPProofType),
+	)
+	if err != nil {
+		return "", fmt.Errorf("oidc: failed to create DPoP signer: %v", err)
+	}
+	jws, err := signer.Sign(payload)
+	if err != nil {
+		return "", fmt.Errorf("oidc: failed to sign DPoP proof: %v", err)
+	}
+	return jws.CompactSerialize()
+}
+
+// ObserveResponse remembers nonce from DPoP-Nonce response header (if any) for the origin of the request.
+// It returns true if new nonce was provided.
+func (p *DPoPProver) ObserveResponse(r *http.Response) bool {
+	if r.Request == nil {
+		return false
+	}
+	return p.observeNonce(r.Request.URL, r.Header)
+}
+
+func (p *DPoPProver) observeNonce(u *url.URL, header http.Header) bool {
+	nonce := header.Get(DPoPNonceHeader)
+	if nonce == "" {
+		return false
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	origin := dpopOrigin(u)
+	if p.nonces[origin] == nonce {
+		return false
+	}
+	p.nonces[origin] = nonce
+	return true
+}
+
+func (p *DPoPProver) nonce(u *url.URL) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.nonces[dpopOrigin(u)]
+}
+
+// setProof sets DPoP proof header for the request.
+func (p *DPoPProver) setProof(r *http.Request, accessToken string) error {
+	proof, err := p.Proof(r.Method, r.URL.String(), accessToken)
+	if err != nil {
+		return err
+	}
+	r.Header.Set(DPoPHeader, proof)
+	return nil
+}
+
+// AccessTokenHash returns base64url encoded SHA-256 hash of the access token as used in "ath" claim of DPoP proof.
+func AccessTokenHash(accessToken string) string {
+	sum := sha256.Sum256([]byte(accessToken))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// dpopHTU returns URL without query and fragment, with lower case scheme and host as used in "htu" claim.
+func dpopHTU(u *url.URL) string {
+	htu := *u
+	htu.Scheme = strings.ToLower(htu.Scheme)
+	htu.Host = strings.ToLower(htu.Host)
+	htu.RawQuery = ""
+	htu.Fragment = ""
+	htu.RawFragment = ""
+	return htu.String()
+}
+
+func dpopOrigin(u *url.URL) string {
+	return strings.ToLower(u.Scheme + "://" + u.Host)
+}
+
+// isDPoPNonceError returns true if server rejected the request because DPoP proof did not contain expected nonce and
+// provided new one.
+func isDPoPNonceError(r *http.Response, body []byte) bool {
+	if r.Header.Get(DPoPNonceHeader) == "" {
+		return false
+	}
+	if r.StatusCode == http.StatusUnauthorized {
+		// Resource servers signal it in WWW-Authenticate header.
+		return strings.Contains(r.Header.Get("WWW-Authenticate"), ErrorCodeUseDPoPNonce)
+	}
+	var e struct {
+		Code string `json:"error"`
+	}
+	return r.StatusCode == http.StatusBadRequest && json.Unmarshal(body, &e) == nil && e.Code == ErrorCodeUseDPoPNonce
+}
+
+var errNoDPoPProver = errors.New("oidc: token is DPoP-bound, but has no DPoPProver attached")
+
+// DefaultDPoPProofMaxAge is a default time for which DPoP proof is accepted after it was issued.
+const DefaultDPoPProofMaxAge = 1 * time.Minute
+
+// DPoPVerifier verifies DPoP proofs on the server side (RFC 9449 section 4.3).
+type DPoPVerifier struct {
+	// Replay remembers proof "jti" claims, so the same proof cannot be used twice. Required.
+	Replay ReplayCache
+	// MaxAge is a time for which proof is accepted after it was issued ("iat"). Defaults to DefaultDPoPProofMaxAge.
+	MaxAge time.Duration
+	// Leeway allows clock skew between client and server when checking "iat".
+	Leeway time.Duration
+	// SupportedSigningAlgs are allowed proof algorithms. Defaults to asymmetric algorithms supported by go-jose.
+	SupportedSigningAlgs []string
+	// Now is a time function. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// Verify verifies DPoP proof for the request with given method and URL. If accessToken is not empty, proof needs to
+// carry its hash ("ath"). It returns RFC 7638 thumbprint of the proof key, which needs to match "cnf.jkt" claim of
+// the access token.
+func (v *DPoPVerifier) Verify(proof string, method string, targetURL string, accessToken string) (string, error) {
+	if v.Replay == nil {
+		return "", errors.New("oidc: Invalid configuration. DPoPVerifier needs Replay cache")
+	}
+
+	jws, err := jose.ParseSigned(proof)
+	if err != nil {
+		return "", fmt.Errorf("oidc: malformed DPoP proof: %v", err)
+	}
+	if len(jws.Signatures) != 1 {
+		return "", errors.New("oidc: DPoP proof needs to have exactly one signature")
+	}
+	header := jws.Signatures[0].Header
+	if typ, _ := header.ExtraHeaders[jose.HeaderType].(string); typ != DPoPProofType {
+		return "", fmt.Errorf("oidc: DPoP proof has wrong typ header %q", typ)
+	}
+	algs := v.SupportedSigningAlgs
+	if len(algs) == 0 {
+		algs = safeSigningAlgs
+	}
+	if !contains(algs, header.Algorithm) {
+		return "", fmt.Errorf("oidc: DPoP proof algorithm %q is not supported", header.Algorithm)
+	}
+	if header.JSONWebKey == nil || !header.JSONWebKey.IsPublic() || !header.JSONWebKey.Valid() {
+		return "", errors.New("oidc: DPoP proof needs to contain valid public jwk header")
+	}
+
+	payload, err := jws.Verify(header.JSONWebKey)
+	if err != nil {
+		return "", fmt.Errorf("oidc: invalid DPoP proof signature: %v", err)
+	}
+
+	var claims dpopProofClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return "", fmt.Errorf("oidc: failed to unmarshal DPoP proof claims: %v", err)
+	}
+	if claims.JTI == "" {
+		return "", errors.New("oidc: DPoP proof has no jti claim")
+	}
+	if claims.Method != method {
+		return "", fmt.Errorf("oidc: DPoP proof htm %q does not match request method %q", claims.Method, method)
+	}
+	u, err := url.Parse(targetURL)
+	if err != nil {
+		return "", fmt.Errorf("oidc: failed to parse request URL: %v", err)
+	}
+	htu, err := url.Parse(claims.URL)
+	if err != nil || dpopHTU(htu) != dpopHTU(u) {
+		return "", fmt.Errorf("oidc: DPoP proof htu %q does not match request URL %q", claims.URL, dpopHTU(u))
+	}
+
+	now := time.Now
+	if v.Now != nil {
+		now = v.Now
+	}
+	maxAge := v.MaxAge
+	if maxAge <= 0 {
+		maxAge = DefaultDPoPProofMaxAge
+	}
+	iat := claims.IssuedAt.Time()
+	if claims.IssuedAt == 0 || iat.After(now().Add(v.Leeway)) || iat.Before(now().Add(-maxAge-v.Leeway)) {
+		return "", fmt.Errorf("oidc: DPoP proof iat %v is outside of acceptable window", iat)
+	}
+
+	if accessToken != "" && claims.AccessTokenHash != AccessTokenHash(accessToken) {
+		return "", errors.New("oidc: DPoP proof ath does not match access token")
+	}
+
+	thumbprint, err := header.JSONWebKey.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return "", fmt.Errorf("oidc: failed to compute DPoP key thumbprint: %v", err)
+	}
+	jkt := base64.RawURLEncoding.EncodeToString(thumbprint)
+
+	// Check replay last, so invalid proofs don't fill the cache.
+	if !v.Replay.Add(jkt+" "+claims.JTI, iat.Add(maxAge+v.Leeway)) {
+		return "", fmt.Errorf("oidc: DPoP proof %q was already used", claims.JTI)
+	}
+	return jkt, nil
+}
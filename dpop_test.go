@@ -0,0 +1,128 @@
+package oidc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bwplotka/go-httpt/rt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+func TestDPoPProofVerification(t *testing.T) {
+	prover, err := NewEphemeralDPoPProver()
+	require.NoError(t, err)
+
+	verifier := &DPoPVerifier{Replay: NewMemoryReplayCache()}
+
+	proof, err := prover.Proof("GET", "https://RS.example.com/resource?x=1#frag", "access1")
+	require.NoError(t, err)
+
+	jws, err := jose.ParseSigned(proof)
+	require.NoError(t, err)
+	assert.Equal(t, DPoPProofType, jws.Signatures[0].Header.ExtraHeaders[jose.HeaderType])
+	assert.True(t, jws.Signatures[0].Header.JSONWebKey.IsPublic())
+	var claims map[string]interface{}
+	require.NoError(t, json.Unmarshal(jws.UnsafePayloadWithoutVerification(), &claims))
+	assert.Equal(t, "https://rs.example.com/resource", claims["htu"])
+	assert.Equal(t, AccessTokenHash("access1"), claims["ath"])
+
+	// Wrong method, URL or access token.
+	_, err = verifier.Verify(proof, "POST", "https://rs.example.com/resource", "access1")
+	assert.Error(t, err)
+	_, err = verifier.Verify(proof, "GET", "https://rs.example.com/other", "access1")
+	assert.Error(t, err)
+	_, err = verifier.Verify(proof, "GET", "https://rs.example.com/resource", "access2")
+	assert.Error(t, err)
+
+	jkt, err := verifier.Verify(proof, "GET", "https://rs.example.com/resource", "access1")
+	require.NoError(t, err)
+	assert.Equal(t, prover.Thumbprint(), jkt)
+
+	// Replay.
+	_, err = verifier.Verify(proof, "GET", "https://rs.example.com/resource", "access1")
+	assert.Error(t, err)
+
+	// Too old and from the future.
+	proof, err = prover.Proof("GET", "https://rs.example.com/resource", "")
+	require.NoError(t, err)
+	_, err = (&DPoPVerifier{Replay: NewMemoryReplayCache(), Now: func() time.Time { return time.Now().Add(2 * time.Minute) }}).
+		Verify(proof, "GET", "https://rs.example.com/resource", "")
+	assert.Error(t, err)
+	_, err = (&DPoPVerifier{Replay: NewMemoryReplayCache(), Now: func() time.Time { return time.Now().Add(-2 * time.Minute) }}).
+		Verify(proof, "GET", "https://rs.example.com/resource", "")
+	assert.Error(t, err)
+
+	// Algorithm not allowed.
+	_, err = (&DPoPVerifier{Replay: NewMemoryReplayCache(), SupportedSigningAlgs: []string{"RS256"}}).
+		Verify(proof, "GET", "https://rs.example.com/resource", "")
+	assert.Error(t, err)
+}
+
+func (s *ClientTestSuite) TestDPoPBoundToken() {
+	prover, err := NewEphemeralDPoPProver()
+	s.Require().NoError(err)
+	verifier := &DPoPVerifier{Replay: NewMemoryReplayCache()}
+
+	cfg := Config{
+		ClientID:     "client1",
+		ClientSecret: "secret1",
+		DPoP:         prover,
+	}
+
+	// First attempt is rejected with nonce, so it is retried with it.
+	s.s.On("POST", testDiscovery.TokenURL).Push(func(r *http.Request) (*http.Response, error) {
+		_, err := verifier.Verify(r.Header.Get(DPoPHeader), "POST", testDiscovery.TokenURL, "")
+		s.Require().NoError(err)
+
+		resp, err := rt.JSONResponseFunc(http.StatusBadRequest, []byte(`{"error": "use_dpop_nonce"}`))(r)
+		resp.Header.Set(DPoPNonceHeader, "nonce1")
+		return resp, err
+	})
+	s.s.On("POST", testDiscovery.TokenURL).Push(func(r *http.Request) (*http.Response, error) {
+		proof := r.Header.Get(DPoPHeader)
+		jkt, err := verifier.Verify(proof, "POST", testDiscovery.TokenURL, "")
+		s.Require().NoError(err)
+		s.Equal(prover.Thumbprint(), jkt)
+
+		jws, err := jose.ParseSigned(proof)
+		s.Require().NoError(err)
+		var claims map[string]interface{}
+		s.Require().NoError(json.Unmarshal(jws.UnsafePayloadWithoutVerification(), &claims))
+		s.Equal("nonce1", claims["nonce"])
+
+		return rt.JSONResponseFunc(http.StatusOK, []byte(`{"access_token": "access1", "token_type": "DPoP", "expires_in": 3600}`))(r)
+	})
+
+	token, err := NewClientCredentialsTokenSource(s.client, cfg, "").OIDCToken(s.testCtx)
+	s.Require().NoError(err)
+	s.Equal(TokenTypeDPoP, token.TokenType)
+	s.Equal(prover, token.DPoP)
+
+	req := httptest.NewRequest("GET", "https://rs.example.com/resource", nil)
+	s.Require().NoError(token.AuthorizeRequest(req))
+	s.Equal("DPoP access1", req.Header.Get("Authorization"))
+	jkt, err := verifier.Verify(req.Header.Get(DPoPHeader), "GET", "https://rs.example.com/resource", "access1")
+	s.Require().NoError(err)
+	s.Equal(prover.Thumbprint(), jkt)
+
+	// DPoP-bound token without prover (e.g loaded from cache) does not authorize the request at all.
+	cached := Token{AccessToken: "access1", TokenType: TokenTypeDPoP}
+	req = httptest.NewRequest("GET", "https://rs.example.com/resource", nil)
+	s.Equal(errNoDPoPProver, cached.AuthorizeRequest(req))
+	cached.SetAuthHeader(req)
+	s.Empty(req.Header.Get("Authorization"))
+	s.Empty(req.Header.Get(DPoPHeader))
+
+	// DPoP-bound token is rejected if DPoP is not configured.
+	s.s.On("POST", testDiscovery.TokenURL).Push(rt.JSONResponseFunc(http.StatusOK, []byte(`{"access_token": "access1", "token_type": "DPoP", "expires_in": 3600}`)))
+	cfg.DPoP = nil
+	_, err = NewClientCredentialsTokenSource(s.client, cfg, "").OIDCToken(s.testCtx)
+	s.Require().Error(err)
+
+	s.Equal(0, s.s.Len())
+}
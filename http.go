@@ -0,0 +1,156 @@
+package oidc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// HTTPClientCtxKey is Context key which is used to fetch custom HTTP.Client.
+// Used to pass special HTTP client (e.g with non-default timeout) or for tests.
+//
+// Deprecated: Kept for backward compatibility. Client passed this way takes precedence over the one configured
+// with NewClient options. Use WithHTTPClient instead.
+var HTTPClientCtxKey struct{}
+
+// newDefaultTransport creates new transport with exactly the same params as default one. Don't use default one directly,
+// because we don't want to depend on it.
+func newDefaultTransport() *http.Transport {
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+			DualStack: true,
+		}).DialContext,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
+
+// httpOptions configure HTTP client shared by all requests of the Client.
+type httpOptions struct {
+	httpClient *http.Client
+	timeout    time.Duration
+	rootCAs    *x509.CertPool
+	proxy      func(*http.Request) (*url.URL, error)
+}
+
+// WithHTTPClient sets HTTP client used for all requests to the provider. It cannot be used together with
+// WithRootCAs or WithProxy, configure the transport of given client instead.
+func WithHTTPClient(client *http.Client) ClientOption {
+	return func(o *clientOptions) {
+		o.httpClient = client
+	}
+}
+
+// WithTimeout sets timeout for every request to the provider. By default there is no timeout, other than the one
+// given by request's context.
+func WithTimeout(timeout time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		o.timeout = timeout
+	}
+}
+
+// WithRootCAs sets root certificate authorities used to verify provider's TLS certificates. System pool is used by default.
+func WithRootCAs(pool *x509.CertPool) ClientOption {
+	return func(o *clientOptions) {
+		o.rootCAs = pool
+	}
+}
+
+// WithProxy sets proxy function as in http.Transport. By default proxy is taken from environment (http.ProxyFromEnvironment).
+func WithProxy(proxy func(*http.Request) (*url.URL, error)) ClientOption {
+	return func(o *clientOptions) {
+		o.proxy = proxy
+	}
+}
+
+// newHTTPClient constructs HTTP client that is shared by all requests of the Client.
+func (o httpOptions) newHTTPClient() (*http.Client, error) {
+	if o.httpClient != nil {
+		if o.rootCAs != nil || o.proxy != nil {
+			return nil, errors.New("oidc: WithRootCAs and WithProxy cannot be used together with WithHTTPClient")
+		}
+		client := *o.httpClient
+		if o.timeout > 0 {
+			client.Timeout = o.timeout
+		}
+		return &client, nil
+	}
+
+	transport := newDefaultTransport()
+	if o.proxy != nil {
+		transport.Proxy = o.proxy
+	}
+	if o.rootCAs != nil {
+		transport.TLSClientConfig = &tls.Config{RootCAs: o.rootCAs}
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   o.timeout,
+	}, nil
+}
+
+// doRequest performs HTTP request using Client's HTTP client or client given by context like this:
+//
+//	context.WithValue(ctx, oidc.HTTPClientCtxKey, client)
+func (c *Client) doRequest(ctx context.Context, req *http.Request) (*http.Response, error) {
+	return doRequestWith(ctx, c.httpClient, req)
+}
+
+func doRequestWith(ctx context.Context, client *http.Client, req *http.Request) (*http.Response, error) {
+	if ctxClient, ok := ctx.Value(HTTPClientCtxKey).(*http.Client); ok {
+		client = ctxClient
+	}
+	return client.Do(req.WithContext(ctx))
+}
+
+// mtlsHTTPClient returns HTTP client that presents certificate from given TLSClientAuth. It shares the configuration
+// of the Client's HTTP client and is created once per TLSClientAuth.
+func (c *Client) mtlsHTTPClient(auth *TLSClientAuth) (*http.Client, error) {
+	c.mtlsClientsMu.Lock()
+	defer c.mtlsClientsMu.Unlock()
+
+	if client, ok := c.mtlsClients[auth]; ok {
+		return client, nil
+	}
+
+	var transport *http.Transport
+	switch t := c.httpClient.Transport.(type) {
+	case nil:
+		transport = newDefaultTransport()
+	case *http.Transport:
+		transport = t.Clone()
+	default:
+		return nil, fmt.Errorf("oidc: mutual TLS requires *http.Transport in HTTP client, got %T", t)
+	}
+
+	tlsConfig := &tls.Config{}
+	if transport.TLSClientConfig != nil {
+		tlsConfig = transport.TLSClientConfig.Clone()
+	}
+	tlsConfig.Certificates = []tls.Certificate{auth.Certificate}
+	if auth.RootCAs != nil {
+		tlsConfig.RootCAs = auth.RootCAs
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   c.httpClient.Timeout,
+	}
+	if c.mtlsClients == nil {
+		c.mtlsClients = map[*TLSClientAuth]*http.Client{}
+	}
+	c.mtlsClients[auth] = client
+	return client, nil
+}
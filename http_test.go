@@ -0,0 +1,120 @@
+package oidc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bwplotka/go-httpt"
+	"github.com/bwplotka/go-httpt/rt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPOptions_NewHTTPClient(t *testing.T) {
+	pool := x509.NewCertPool()
+	proxyURL, err := url.Parse("http://proxy.org")
+	require.NoError(t, err)
+
+	client, err := httpOptions{
+		timeout: 5 * time.Second,
+		rootCAs: pool,
+		proxy:   http.ProxyURL(proxyURL),
+	}.newHTTPClient()
+	require.NoError(t, err)
+	assert.Equal(t, 5*time.Second, client.Timeout)
+
+	transport := client.Transport.(*http.Transport)
+	assert.Equal(t, pool, transport.TLSClientConfig.RootCAs)
+	gotProxy, err := transport.Proxy(httptest.NewRequest("GET", "https://issuer.org", nil))
+	require.NoError(t, err)
+	assert.Equal(t, proxyURL, gotProxy)
+
+	custom := &http.Client{}
+	client, err = httpOptions{httpClient: custom, timeout: 5 * time.Second}.newHTTPClient()
+	require.NoError(t, err)
+	assert.Equal(t, 5*time.Second, client.Timeout)
+	assert.Equal(t, time.Duration(0), custom.Timeout, "given client should not be modified")
+
+	_, err = httpOptions{httpClient: custom, rootCAs: pool}.newHTTPClient()
+	require.Error(t, err)
+}
+
+func TestNewClient_WithHTTPClient(t *testing.T) {
+	s := httpt.NewServer(t)
+
+	jsonDiscovery, err := json.Marshal(testDiscovery)
+	require.NoError(t, err)
+	s.On("GET", exampleIssuer+DiscoveryEndpoint).Push(rt.JSONResponseFunc(http.StatusOK, jsonDiscovery))
+
+	// No HTTPClientCtxKey in context.
+	client, err := NewClient(context.Background(), exampleIssuer, WithHTTPClient(s.HTTPClient()))
+	require.NoError(t, err)
+
+	s.On("GET", testDiscovery.JWKSURL).Push(rt.JSONResponseFunc(http.StatusOK, []byte(`{"keys": []}`)))
+	_, err = client.Verifier(VerificationConfig{ClientID: "client1"}).keySet.Keys(context.Background())
+	require.NoError(t, err)
+
+	require.Equal(t, 0, s.Len())
+}
+
+func TestNewClient_ReusesConnections(t *testing.T) {
+	var newConns int32
+	srv := httptest.NewUnstartedServer(nil)
+	srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"issuer": %q, "jwks_uri": %q}`, srv.URL, srv.URL+"/jwks")
+	})
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&newConns, 1)
+		}
+	}
+	srv.StartTLS()
+	defer srv.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+
+	client, err := NewClient(context.Background(), srv.URL, WithRootCAs(pool), WithTimeout(5*time.Second))
+	require.NoError(t, err)
+	require.NoError(t, client.Refresh(context.Background()))
+	require.NoError(t, client.Refresh(context.Background()))
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&newConns))
+
+	// Client without our root CA should fail to verify server's certificate.
+	_, err = NewClient(context.Background(), srv.URL)
+	require.Error(t, err)
+}
+
+func TestClient_MTLSHTTPClient(t *testing.T) {
+	pool := x509.NewCertPool()
+	httpClient, err := httpOptions{rootCAs: pool, timeout: time.Second}.newHTTPClient()
+	require.NoError(t, err)
+
+	c := &Client{httpClient: httpClient}
+	auth := &TLSClientAuth{Certificate: tls.Certificate{Certificate: [][]byte{[]byte("cert1")}}}
+
+	mtlsClient, err := c.mtlsHTTPClient(auth)
+	require.NoError(t, err)
+	assert.Equal(t, time.Second, mtlsClient.Timeout)
+
+	tlsConfig := mtlsClient.Transport.(*http.Transport).TLSClientConfig
+	assert.Equal(t, pool, tlsConfig.RootCAs)
+	assert.Equal(t, []tls.Certificate{auth.Certificate}, tlsConfig.Certificates)
+	assert.Nil(t, httpClient.Transport.(*http.Transport).TLSClientConfig.Certificates, "base client should not be modified")
+
+	mtlsClient2, err := c.mtlsHTTPClient(auth)
+	require.NoError(t, err)
+	assert.True(t, mtlsClient == mtlsClient2, "client should be created once per TLSClientAuth")
+}
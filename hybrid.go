@@ -0,0 +1,87 @@
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+// Response types of hybrid and implicit flows (OIDC Core sections 3.2 and 3.3). Tokens in these flows are returned
+// directly from the authorization endpoint, by default in URL fragment.
+const (
+	ResponseTypeCodeIDToken      = "code id_token"
+	ResponseTypeCodeToken        = "code token"
+	ResponseTypeCodeIDTokenToken = "code id_token token"
+	ResponseTypeIDTokenToken     = "id_token token"
+)
+
+// Response modes as defined in OAuth 2.0 Multiple Response Type Encoding Practices and OAuth 2.0 Form Post
+// Response Mode. For JWT secured response modes see ResponseModeJWT.
+const (
+	ResponseModeQuery    = "query"
+	ResponseModeFragment = "fragment"
+	ResponseModeFormPost = "form_post"
+)
+
+// ResponseTypeContains returns true if space separated responseType contains given value, e.g "code id_token"
+// contains "id_token".
+func ResponseTypeContains(responseType string, value string) bool {
+	return contains(strings.Fields(responseType), value)
+}
+
+// DefaultResponseMode returns response mode provider uses when none is requested: "query" for "code" (and empty)
+// response type and "fragment" for all response types that return tokens from the authorization endpoint.
+func DefaultResponseMode(responseType string) string {
+	if ResponseTypeContains(responseType, ResponseTypeToken) || ResponseTypeContains(responseType, ResponseTypeIDToken) {
+		return ResponseModeFragment
+	}
+	return ResponseModeQuery
+}
+
+// TokenHash returns hash of value as used in "at_hash" and "c_hash" ID token claims: base64url encoded left-most half
+// of the hash of the value, where hash function is the one used by the ID token's JWS algorithm.
+func TokenHash(alg string, value string) (string, error) {
+	var h hash.Hash
+	switch jose.SignatureAlgorithm(alg) {
+	case jose.RS256, jose.PS256, jose.ES256, jose.HS256:
+		h = sha256.New()
+	case jose.RS384, jose.PS384, jose.ES384, jose.HS384:
+		h = sha512.New384()
+	case jose.RS512, jose.PS512, jose.ES512, jose.HS512, jose.EdDSA:
+		h = sha512.New()
+	default:
+		return "", fmt.Errorf("oidc: cannot compute token hash for %q algorithm", alg)
+	}
+	h.Write([]byte(value))
+	sum := h.Sum(nil)
+	return base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2]), nil
+}
+
+// VerifyFrontChannelIDToken verifies ID token returned directly from the authorization endpoint in implicit or hybrid
+// flow (OIDC Core sections 3.2.2.11 and 3.3.2.12). Apart from regular ID token verification, nonce is required, so
+// cfg.ClaimNonce needs to be set to the nonce sent in the authorization request. If code is not empty (hybrid flow),
+// "c_hash" claim needs to match it. Verify the code this way before exchanging it.
+func (c *Client) VerifyFrontChannelIDToken(ctx context.Context, cfg VerificationConfig, rawIDToken string, code string) (*IDToken, error) {
+	if cfg.ClaimNonce == "" {
+		return nil, errors.New("oidc: Invalid configuration. ClaimNonce is required for ID token from authorization endpoint")
+	}
+
+	idToken, err := c.Verifier(cfg).Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if code != "" {
+		if err := idToken.VerifyCode(code); err != nil {
+			return nil, err
+		}
+	}
+	return idToken, nil
+}
@@ -0,0 +1,80 @@
+package oidc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bwplotka/go-jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenHash(t *testing.T) {
+	// Example from OIDC Core Appendix A.4.
+	h, err := TokenHash("RS256", "Qcb0Orv1zh30vL1MPRsbm-diHiMwcLyZvn1arpZv-Jxf_11jnpEX3Tgfvk")
+	require.NoError(t, err)
+	assert.Equal(t, "LDktKdoQak3Pk0cnXxCltA", h)
+
+	_, err = TokenHash("none", "code1")
+	require.Error(t, err)
+}
+
+func TestDefaultResponseMode(t *testing.T) {
+	assert.Equal(t, ResponseModeQuery, DefaultResponseMode(""))
+	assert.Equal(t, ResponseModeQuery, DefaultResponseMode(ResponseTypeCode))
+	assert.Equal(t, ResponseModeFragment, DefaultResponseMode(ResponseTypeCodeIDToken))
+	assert.Equal(t, ResponseModeFragment, DefaultResponseMode(ResponseTypeIDTokenToken))
+}
+
+func TestVerifyFrontChannelIDToken(t *testing.T) {
+	builder, err := jwt.NewDefaultBuilder()
+	require.NoError(t, err)
+
+	c := &Client{
+		issuer:    issuerValidator{issuer: exampleIssuer},
+		discovery: DiscoveryJSON{Issuer: exampleIssuer},
+		keySet:    staticKeySet{builder.PublicJWK()},
+	}
+
+	cHash, err := TokenHash("RS256", "code1")
+	require.NoError(t, err)
+	newIDToken := func(nonce string, cHash string) string {
+		claims := map[string]interface{}{
+			"iss":   exampleIssuer,
+			"aud":   "client1",
+			"sub":   "subject1",
+			"exp":   time.Now().Add(1 * time.Minute).Unix(),
+			"nonce": nonce,
+		}
+		if cHash != "" {
+			claims["c_hash"] = cHash
+		}
+		token, err := builder.JWS().Claims(claims).CompactSerialize()
+		require.NoError(t, err)
+		return token
+	}
+	cfg := VerificationConfig{ClientID: "client1", ClaimNonce: "nonce1"}
+
+	idToken, err := c.VerifyFrontChannelIDToken(context.Background(), cfg, newIDToken("nonce1", cHash), "code1")
+	require.NoError(t, err)
+	assert.Equal(t, "subject1", idToken.Subject)
+
+	// Implicit flow without code.
+	_, err = c.VerifyFrontChannelIDToken(context.Background(), cfg, newIDToken("nonce1", ""), "")
+	require.NoError(t, err)
+
+	_, err = c.VerifyFrontChannelIDToken(context.Background(), cfg, newIDToken("nonce1", cHash), "code2")
+	require.Error(t, err)
+	assert.Equal(t, "oidc: ID token c_hash claim does not match", err.Error())
+
+	_, err = c.VerifyFrontChannelIDToken(context.Background(), cfg, newIDToken("nonce1", ""), "code1")
+	require.Error(t, err)
+	assert.Equal(t, "oidc: ID token has no c_hash claim", err.Error())
+
+	_, err = c.VerifyFrontChannelIDToken(context.Background(), cfg, newIDToken("nonce2", cHash), "code1")
+	require.Error(t, err)
+
+	_, err = c.VerifyFrontChannelIDToken(context.Background(), VerificationConfig{ClientID: "client1"}, newIDToken("", cHash), "code1")
+	require.Error(t, err)
+}
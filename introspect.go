@@ -0,0 +1,83 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// IntrospectionResponse is a Token Introspection response as described in RFC 7662 section 2.2.
+//
+// Only Active field is required by the spec. To access additional claims returned by the server, use the Claims method.
+type IntrospectionResponse struct {
+	// Active indicates whether the token is currently active.
+	Active bool `json:"active"`
+
+	// Scope is a space-separated list of scopes associated with the token.
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+
+	Expiry    NumericDate `json:"exp,omitempty"`
+	IssuedAt  NumericDate `json:"iat,omitempty"`
+	NotBefore NumericDate `json:"nbf,omitempty"`
+
+	Subject  string   `json:"sub,omitempty"`
+	Audience Audience `json:"aud,omitempty"`
+	Issuer   string   `json:"iss,omitempty"`
+	JTI      string   `json:"jti,omitempty"`
+
+	// Raw introspection response.
+	claims []byte
+}
+
+// Scopes returns scopes associated with the token.
+func (r *IntrospectionResponse) Scopes() []string {
+	return strings.Fields(r.Scope)
+}
+
+// Claims unmarshals the raw JSON introspection response into the provided object.
+func (r *IntrospectionResponse) Claims(v interface{}) error {
+	if r.claims == nil {
+		return errors.New("oidc: claims not set")
+	}
+	return json.Unmarshal(r.claims, v)
+}
+
+// Introspect queries the provider's introspection endpoint about given token (RFC 7662). Token can be opaque.
+// Use "token_type_hint" in extra values to help the provider find the token.
+// NOTE: Inactive token is not an error. Always check IntrospectionResponse.Active.
+func (c *Client) Introspect(ctx context.Context, cfg Config, token string, extra ...url.Values) (*IntrospectionResponse, error) {
+	if c.Discovery().IntrospectionURL == "" {
+		return nil, errors.New("oidc: introspection endpoint is not supported by this provider")
+	}
+
+	v := url.Values{
+		"token": {token},
+	}
+
+	for _, e := range extra {
+		for key := range e {
+			v.Set(key, e.Get(key))
+		}
+	}
+
+	r, body, err := c.postForm(ctx, cfg, c.Discovery().IntrospectionURL, v)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: cannot introspect token: %v", err)
+	}
+	if code := r.StatusCode; code < 200 || code > 299 {
+		return nil, fmt.Errorf("oidc: cannot introspect token: %v\nResponse: %s", r.Status, body)
+	}
+
+	var resp IntrospectionResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("oidc: failed to decode introspection response: %v", err)
+	}
+	resp.claims = body
+	return &resp, nil
+}
@@ -0,0 +1,105 @@
+package oidc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TenantPlaceholder is a placeholder for tenant in issuer template e.g "https://login.microsoftonline.com/{tenantid}/v2.0".
+const TenantPlaceholder = "{tenantid}"
+
+// issuerOptions are issuer validation options of the Client. See WithIssuerAliases and WithIssuerTemplate.
+type issuerOptions struct {
+	issuerAliases  []string
+	issuerTemplate string
+	tenants        []string
+}
+
+// WithIssuerAliases specifies additional issuer values accepted in discovery document and in "iss" claim of ID tokens.
+// By default issuer must match the one passed to NewClient exactly.
+func WithIssuerAliases(aliases ...string) ClientOption {
+	return func(o *clientOptions) {
+		o.issuerAliases = append(o.issuerAliases, aliases...)
+	}
+}
+
+// WithIssuerTemplate allows multi-tenant providers, where discovery document (e.g Azure AD "common" endpoint) returns
+// issuer template with TenantPlaceholder and each ID token is issued by the tenant-specific issuer.
+// The tenant resolved from "iss" claim is exposed as IDToken.Tenant. If allowedTenants are specified, only tokens
+// from these tenants are accepted. Otherwise any tenant is accepted, so make sure to authorize the tenant yourself.
+func WithIssuerTemplate(template string, allowedTenants ...string) ClientOption {
+	return func(o *clientOptions) {
+		o.issuerTemplate = template
+		o.tenants = append(o.tenants, allowedTenants...)
+	}
+}
+
+// issuerValidator validates issuer returned by the provider in discovery document and in ID tokens.
+type issuerValidator struct {
+	issuer   string
+	aliases  []string
+	template string
+	tenants  []string
+}
+
+func newIssuerValidator(issuer string, o issuerOptions) (issuerValidator, error) {
+	v := issuerValidator{
+		issuer:   issuer,
+		aliases:  o.issuerAliases,
+		template: o.issuerTemplate,
+		tenants:  o.tenants,
+	}
+	if v.template != "" && strings.Count(v.template, TenantPlaceholder) != 1 {
+		return issuerValidator{}, fmt.Errorf("oidc: issuer template %q needs to contain exactly one %s placeholder", v.template, TenantPlaceholder)
+	}
+	if issuer == issuerGoogleAccounts {
+		// Google sometimes returns "accounts.google.com" as the issuer claim instead of
+		// the required "https://accounts.google.com".
+		v.aliases = append(v.aliases, issuerGoogleAccountsNoScheme)
+	}
+	return v, nil
+}
+
+// validateDiscovery checks issuer from discovery document. Multi-tenant providers return issuer template as is.
+func (v issuerValidator) validateDiscovery(iss string) error {
+	if iss == v.issuer || contains(v.aliases, iss) || (v.template != "" && iss == v.template) {
+		return nil
+	}
+	return fmt.Errorf("oidc: issuer did not match the issuer returned by provider, expected %q got %q", v.issuer, iss)
+}
+
+// audience returns audience for JWTs the client sends to the provider (request objects, client assertions).
+// It is issuer iss from discovery document. For multi-tenant providers iss is issuer template, which is never accepted as
+// audience, so it is resolved to the tenant issuer if exactly one tenant is allowed. Otherwise endpoint is returned.
+func (v issuerValidator) audience(iss string, endpoint string) string {
+	if v.template == "" || iss != v.template {
+		return iss
+	}
+	if len(v.tenants) == 1 {
+		return strings.Replace(iss, TenantPlaceholder, v.tenants[0], 1)
+	}
+	return endpoint
+}
+
+// validateToken checks "iss" claim of the token and returns resolved tenant if issuer template is configured.
+func (v issuerValidator) validateToken(iss string) (tenant string, err error) {
+	if iss == v.issuer || contains(v.aliases, iss) {
+		return "", nil
+	}
+
+	if v.template != "" {
+		idx := strings.Index(v.template, TenantPlaceholder)
+		prefix, suffix := v.template[:idx], v.template[idx+len(TenantPlaceholder):]
+		if len(iss) > len(prefix)+len(suffix) && strings.HasPrefix(iss, prefix) && strings.HasSuffix(iss, suffix) {
+			tenant = iss[len(prefix) : len(iss)-len(suffix)]
+			if strings.Contains(tenant, "/") {
+				return "", fmt.Errorf("oidc: id token issuer %q does not match issuer template %q", iss, v.template)
+			}
+			if len(v.tenants) > 0 && !contains(v.tenants, tenant) {
+				return "", fmt.Errorf("oidc: id token issued by tenant %q which is not allowed", tenant)
+			}
+			return tenant, nil
+		}
+	}
+	return "", fmt.Errorf("oidc: id token issued by a different provider, expected %q got %q", v.issuer, iss)
+}
@@ -0,0 +1,201 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bwplotka/go-httpt"
+	"github.com/bwplotka/go-httpt/rt"
+	"github.com/bwplotka/go-jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+)
+
+func TestIssuerValidator(t *testing.T) {
+	for _, tcase := range []struct {
+		name    string
+		issuer  string
+		opts    []ClientOption
+		iss     string
+		tenant  string
+		wantErr bool
+	}{
+		{name: "exact", issuer: "https://issuer.org", iss: "https://issuer.org"},
+		{name: "different", issuer: "https://issuer.org", iss: "https://issuer2.org", wantErr: true},
+		{name: "trailing slash", issuer: "https://issuer.org", iss: "https://issuer.org/", wantErr: true},
+		{name: "alias", issuer: "https://issuer.org", opts: []ClientOption{WithIssuerAliases("https://old.issuer.org")}, iss: "https://old.issuer.org"},
+		{name: "google without scheme", issuer: issuerGoogleAccounts, iss: issuerGoogleAccountsNoScheme},
+		{name: "no scheme for non google", issuer: "https://issuer.org", iss: "issuer.org", wantErr: true},
+		{
+			name:   "template any tenant",
+			issuer: "https://login.microsoftonline.com/common/v2.0",
+			opts:   []ClientOption{WithIssuerTemplate("https://login.microsoftonline.com/{tenantid}/v2.0")},
+			iss:    "https://login.microsoftonline.com/tenant1/v2.0",
+			tenant: "tenant1",
+		},
+		{
+			name:   "template allowed tenant",
+			issuer: "https://login.microsoftonline.com/common/v2.0",
+			opts:   []ClientOption{WithIssuerTemplate("https://login.microsoftonline.com/{tenantid}/v2.0", "tenant1", "tenant2")},
+			iss:    "https://login.microsoftonline.com/tenant2/v2.0",
+			tenant: "tenant2",
+		},
+		{
+			name:    "template not allowed tenant",
+			issuer:  "https://login.microsoftonline.com/common/v2.0",
+			opts:    []ClientOption{WithIssuerTemplate("https://login.microsoftonline.com/{tenantid}/v2.0", "tenant1")},
+			iss:     "https://login.microsoftonline.com/tenant3/v2.0",
+			wantErr: true,
+		},
+		{
+			name:    "template tenant with path",
+			issuer:  "https://login.microsoftonline.com/common/v2.0",
+			opts:    []ClientOption{WithIssuerTemplate("https://login.microsoftonline.com/{tenantid}/v2.0")},
+			iss:     "https://login.microsoftonline.com/tenant1/x/v2.0",
+			wantErr: true,
+		},
+		{
+			name:    "template empty tenant",
+			issuer:  "https://login.microsoftonline.com/common/v2.0",
+			opts:    []ClientOption{WithIssuerTemplate("https://login.microsoftonline.com/{tenantid}/v2.0")},
+			iss:     "https://login.microsoftonline.com//v2.0",
+			wantErr: true,
+		},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			var o clientOptions
+			for _, opt := range tcase.opts {
+				opt(&o)
+			}
+			v, err := newIssuerValidator(tcase.issuer, o.issuerOptions)
+			require.NoError(t, err)
+
+			tenant, err := v.validateToken(tcase.iss)
+			if tcase.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tcase.tenant, tenant)
+		})
+	}
+
+	_, err := newIssuerValidator("https://issuer.org", issuerOptions{issuerTemplate: "https://issuer.org/tenant"})
+	require.Error(t, err)
+}
+
+func TestNewClient_IssuerTemplate(t *testing.T) {
+	const (
+		commonIssuer   = "https://login.microsoftonline.com/common/v2.0"
+		issuerTemplate = "https://login.microsoftonline.com/{tenantid}/v2.0"
+	)
+
+	s := httpt.NewServer(t)
+	ctx := context.WithValue(context.TODO(), HTTPClientCtxKey, s.HTTPClient())
+
+	discovery, err := json.Marshal(DiscoveryJSON{
+		Issuer:  issuerTemplate,
+		JWKSURL: "https://login.microsoftonline.com/common/discovery/v2.0/keys",
+	})
+	require.NoError(t, err)
+
+	// Template is rejected by default.
+	s.On("GET", commonIssuer+DiscoveryEndpoint).Push(rt.JSONResponseFunc(http.StatusOK, discovery))
+	_, err = NewClient(ctx, commonIssuer)
+	require.Error(t, err)
+
+	s.On("GET", commonIssuer+DiscoveryEndpoint).Push(rt.JSONResponseFunc(http.StatusOK, discovery))
+	client, err := NewClient(ctx, commonIssuer, WithIssuerTemplate(issuerTemplate, "tenant1"))
+	require.NoError(t, err)
+
+	builder, err := jwt.NewDefaultBuilder()
+	require.NoError(t, err)
+	jwkSetJSON, err := json.Marshal(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{builder.PublicJWK()}})
+	require.NoError(t, err)
+
+	newIDToken := func(iss string) string {
+		token, err := builder.JWS().Claims(&IDToken{
+			Issuer:   iss,
+			Expiry:   NewNumericDate(time.Now().Add(1 * time.Hour)),
+			Subject:  "subject1",
+			Audience: []string{"client1"},
+		}).CompactSerialize()
+		require.NoError(t, err)
+		return token
+	}
+
+	verifier := client.Verifier(VerificationConfig{ClientID: "client1"})
+
+	s.On("GET", "https://login.microsoftonline.com/common/discovery/v2.0/keys").Push(rt.JSONResponseFunc(http.StatusOK, jwkSetJSON))
+	idToken, err := verifier.Verify(ctx, newIDToken("https://login.microsoftonline.com/tenant1/v2.0"))
+	require.NoError(t, err)
+	assert.Equal(t, "tenant1", idToken.Tenant)
+
+	_, err = verifier.Verify(ctx, newIDToken("https://login.microsoftonline.com/tenant2/v2.0"))
+	require.Error(t, err)
+
+	require.Equal(t, 0, s.Len())
+}
+
+func (s *ClientTestSuite) TestIssuerTemplate_Audience() {
+	const issuerTemplate = exampleIssuer + "/{tenantid}"
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	s.Require().NoError(err)
+	cfg := Config{
+		ClientID:            "client1",
+		ClientAuth:          &PrivateKeyJWT{Signer: key, KeyID: "kid1"},
+		RequestObjectSigner: &RequestObjectSigner{Signer: key, KeyID: "kid1"},
+	}
+
+	for _, tcase := range []struct {
+		name                    string
+		tenants                 []string
+		expectedRevokeAudience  string
+		expectedRequestAudience string
+	}{
+		{
+			name:                    "single tenant",
+			tenants:                 []string{"tenant1"},
+			expectedRevokeAudience:  exampleIssuer + "/tenant1",
+			expectedRequestAudience: exampleIssuer + "/tenant1",
+		},
+		{
+			name:                    "any tenant",
+			expectedRevokeAudience:  testDiscovery.RevocationURL,
+			expectedRequestAudience: testDiscovery.AuthURL,
+		},
+	} {
+		s.Run(tcase.name, func() {
+			client := s.clientWithDiscovery(func(d *DiscoveryJSON) {
+				d.Issuer = issuerTemplate
+			})
+			client.issuer, err = newIssuerValidator(exampleIssuer, issuerOptions{issuerTemplate: issuerTemplate, tenants: tcase.tenants})
+			s.Require().NoError(err)
+
+			requestObject, err := client.RequestObject(cfg, "state1")
+			s.Require().NoError(err)
+			jws, err := jose.ParseSigned(requestObject)
+			s.Require().NoError(err)
+			payload, err := jws.Verify(&key.PublicKey)
+			s.Require().NoError(err)
+			var claims map[string]interface{}
+			s.Require().NoError(json.Unmarshal(payload, &claims))
+			s.Equal(tcase.expectedRequestAudience, claims["aud"])
+
+			s.s.On("POST", testDiscovery.RevocationURL).Push(func(req *http.Request) (*http.Response, error) {
+				s.verifyClientAssertion(req, &key.PublicKey, "kid1", tcase.expectedRevokeAudience)
+				return rt.StringResponseFunc(http.StatusOK, "")(req)
+			})
+			s.Require().NoError(client.Revoke(s.testCtx, cfg, "access1"))
+			s.Equal(0, s.s.Len())
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package oidc
+
+import (
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+const (
+	// DefaultRequestObjectLifetime is a default time after which request object expires.
+	DefaultRequestObjectLifetime = 5 * time.Minute
+
+	// requestObjectType is a "typ" header of request objects (RFC 9101 section 10.8).
+	requestObjectType = "oauth-authz-req+jwt"
+)
+
+// RequestObjectSigner signs authorization request parameters into request object (JAR, RFC 9101).
+// Either Signer or Key needs to be specified. Provider needs to know the public key e.g from client's registered JWKS.
+type RequestObjectSigner struct {
+	// Signer signs the request object. It can be any crypto.Signer with RSA, ECDSA or Ed25519 key e.g backed by HSM or KMS.
+	Signer crypto.Signer
+	// Key is a private JWK used if Signer is not specified. Its "kid" and "alg" are used if KeyID and Algorithm are empty.
+	Key *jose.JSONWebKey
+
+	// Algorithm is a JWS algorithm. If empty, it is derived from the key type: RS256 for RSA, ES256/ES384/ES512 for ECDSA
+	// depending on the curve and EdDSA for Ed25519.
+	Algorithm jose.SignatureAlgorithm
+	// KeyID is set as "kid" header. If empty, RFC 7638 thumbprint of the public key is used.
+	KeyID string
+	// Lifetime of the request object. Defaults to DefaultRequestObjectLifetime.
+	Lifetime time.Duration
+}
+
+func (s *RequestObjectSigner) signer() (*cryptoSigner, error) {
+	signer, alg, keyID := s.Signer, s.Algorithm, s.KeyID
+	if signer == nil {
+		if s.Key == nil {
+			return nil, errors.New("oidc: request object signer has neither Signer nor Key specified")
+		}
+		var ok bool
+		signer, ok = s.Key.Key.(crypto.Signer)
+		if !ok || s.Key.IsPublic() {
+			return nil, fmt.Errorf("oidc: request object key needs to be a private key, got %T", s.Key.Key)
+		}
+		if alg == "" {
+			alg = jose.SignatureAlgorithm(s.Key.Algorithm)
+		}
+		if keyID == "" {
+			keyID = s.Key.KeyID
+		}
+	}
+	return newCryptoSigner(signer, alg, keyID)
+}
+
+// RequestObject returns authorization request parameters (the same as AuthCodeURL would use) signed as request object
+// with cfg.RequestObjectSigner. Claims "iss" (client ID), "aud" (provider's issuer), "iat", "nbf", "exp" and unique
+// "jti" are set. For multi-tenant providers "aud" is the tenant issuer if exactly one tenant is allowed, otherwise
+// authorization endpoint (see WithIssuerTemplate).
+//
+// Use SignedAuthCodeURL to pass it by value. To pass it by reference, host it under URL registered with
+// the provider and pass that URL to AuthCodeURLWithRequestURI.
+func (c *Client) RequestObject(cfg Config, state string, extra ...url.Values) (string, error) {
+	if cfg.RequestObjectSigner == nil {
+		return "", errors.New("oidc: RequestObjectSigner is not configured")
+	}
+	signer, err := cfg.RequestObjectSigner.signer()
+	if err != nil {
+		return "", err
+	}
+
+	discovery := c.Discovery()
+	if !supportedOrNotAdvertised(discovery.RequestObjectSigningAlgValuesSupported, string(signer.alg)) {
+		return "", fmt.Errorf("oidc: provider does not support %s request object signing algorithm. Supported: %v",
+			signer.alg, discovery.RequestObjectSigningAlgValuesSupported)
+	}
+
+	claims := map[string]interface{}{}
+	params := authCodeParams(cfg, state, extra...)
+	for key := range params {
+		claims[key] = requestObjectClaim(key, params.Get(key))
+	}
+
+	lifetime := cfg.RequestObjectSigner.Lifetime
+	if lifetime <= 0 {
+		lifetime = DefaultRequestObjectLifetime
+	}
+	now := time.Now()
+	claims["iss"] = cfg.ClientID
+	claims["aud"] = c.issuer.audience(discovery.Issuer, discovery.AuthURL)
+	claims["iat"] = NewNumericDate(now)
+	claims["nbf"] = NewNumericDate(now)
+	claims["exp"] = NewNumericDate(now.Add(lifetime))
+	claims["jti"] = randomString(16)
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	s, err := jose.NewSigner(jose.SigningKey{Algorithm: signer.alg, Key: signer}, (&jose.SignerOptions{}).WithType(requestObjectType))
+	if err != nil {
+		return "", fmt.Errorf("oidc: failed to create request object signer: %v", err)
+	}
+	jws, err := s.Sign(payload)
+	if err != nil {
+		return "", fmt.Errorf("oidc: failed to sign request object: %v", err)
+	}
+	return jws.CompactSerialize()
+}
+
+// requestObjectClaim converts authorization request parameter into JSON claim. Parameters that are JSON objects or
+// numbers in the request object are converted, rest are passed as strings.
+func requestObjectClaim(key string, value string) interface{} {
+	switch key {
+	case "claims":
+		if json.Valid([]byte(value)) {
+			return json.RawMessage(value)
+		}
+	case "max_age":
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+	}
+	return value
+}
+
+// SignedAuthCodeURL is like AuthCodeURL, but authorization request parameters are passed as request object signed
+// with cfg.RequestObjectSigner (JAR, RFC 9101). Parameters client_id, response_type and scope are duplicated
+// in the URL as required by OpenID Connect.
+func (c *Client) SignedAuthCodeURL(cfg Config, state string, extra ...url.Values) (string, error) {
+	requestObject, err := c.RequestObject(cfg, state, extra...)
+	if err != nil {
+		return "", err
+	}
+
+	params := authCodeParams(cfg, state, extra...)
+	v := url.Values{
+		"client_id":     {cfg.ClientID},
+		"response_type": {params.Get("response_type")},
+		"request":       {requestObject},
+	}
+	if scope := params.Get("scope"); scope != "" {
+		v.Set("scope", scope)
+	}
+	return appendQuery(c.Discovery().AuthURL, v), nil
+}
@@ -0,0 +1,100 @@
+package oidc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/url"
+	"time"
+
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+func (s *ClientTestSuite) TestSignedAuthCodeURL() {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	s.Require().NoError(err)
+
+	cfg := Config{
+		ClientID:            "client1",
+		RedirectURL:         "http://127.0.0.1/callback",
+		Scopes:              []string{ScopeOpenID},
+		RequestObjectSigner: &RequestObjectSigner{Signer: key, KeyID: "kid1"},
+	}
+
+	authURL, err := s.client.SignedAuthCodeURL(cfg, "state1", url.Values{
+		"login_hint": {"user@example.com"},
+		"max_age":    {"60"},
+		"claims":     {`{"id_token":{"acr":{"essential":true}}}`},
+	})
+	s.Require().NoError(err)
+
+	u, err := url.Parse(authURL)
+	s.Require().NoError(err)
+	s.Equal(exampleIssuer+"/auth1", u.Scheme+"://"+u.Host+u.Path)
+	q := u.Query()
+	s.Equal("client1", q.Get("client_id"))
+	s.Equal(ResponseTypeCode, q.Get("response_type"))
+	s.Equal(ScopeOpenID, q.Get("scope"))
+	s.Empty(q.Get("login_hint"))
+
+	jws, err := jose.ParseSigned(q.Get("request"))
+	s.Require().NoError(err)
+	s.Equal("kid1", jws.Signatures[0].Header.KeyID)
+	s.Equal("RS256", jws.Signatures[0].Header.Algorithm)
+	s.Equal(requestObjectType, jws.Signatures[0].Header.ExtraHeaders[jose.HeaderType])
+
+	payload, err := jws.Verify(&key.PublicKey)
+	s.Require().NoError(err)
+
+	var claims map[string]interface{}
+	s.Require().NoError(json.Unmarshal(payload, &claims))
+	s.Equal("client1", claims["iss"])
+	s.Equal(exampleIssuer, claims["aud"])
+	s.Equal("client1", claims["client_id"])
+	s.Equal("state1", claims["state"])
+	s.Equal("user@example.com", claims["login_hint"])
+	s.Equal(float64(60), claims["max_age"])
+	s.Equal(map[string]interface{}{"id_token": map[string]interface{}{"acr": map[string]interface{}{"essential": true}}}, claims["claims"])
+	s.NotEmpty(claims["jti"])
+	s.Equal(claims["iat"], claims["nbf"])
+	s.Equal(claims["nbf"].(float64)+DefaultRequestObjectLifetime.Seconds(), claims["exp"])
+
+	_, err = s.client.SignedAuthCodeURL(Config{ClientID: "client1"}, "state1")
+	s.Require().Error(err)
+}
+
+func (s *ClientTestSuite) TestRequestObject_JWKAndAlgCheck() {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	s.Require().NoError(err)
+
+	cfg := Config{
+		ClientID: "client1",
+		RequestObjectSigner: &RequestObjectSigner{
+			Key:      &jose.JSONWebKey{Key: ecKey, KeyID: "jwk1", Algorithm: "ES256"},
+			Lifetime: 1 * time.Minute,
+		},
+	}
+	requestObject, err := s.client.RequestObject(cfg, "state1")
+	s.Require().NoError(err)
+
+	jws, err := jose.ParseSigned(requestObject)
+	s.Require().NoError(err)
+	s.Equal("jwk1", jws.Signatures[0].Header.KeyID)
+	_, err = jws.Verify(&ecKey.PublicKey)
+	s.Require().NoError(err)
+
+	// Public JWK cannot sign.
+	cfg.RequestObjectSigner = &RequestObjectSigner{Key: &jose.JSONWebKey{Key: &ecKey.PublicKey}}
+	_, err = s.client.RequestObject(cfg, "state1")
+	s.Require().Error(err)
+
+	client := s.clientWithDiscovery(func(d *DiscoveryJSON) {
+		d.RequestObjectSigningAlgValuesSupported = []string{"PS256"}
+	})
+	cfg.RequestObjectSigner = &RequestObjectSigner{Signer: ecKey}
+	_, err = client.RequestObject(cfg, "state1")
+	s.Require().Error(err)
+	s.Equal("oidc: provider does not support ES256 request object signing algorithm. Supported: [PS256]", err.Error())
+}
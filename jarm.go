@@ -0,0 +1,101 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+// Response modes for JWT Secured Authorization Response Mode (JARM). Authorization response parameters are passed
+// in the "response" parameter as JWT signed by the provider.
+const (
+	ResponseModeJWT         = "jwt"
+	ResponseModeQueryJWT    = "query.jwt"
+	ResponseModeFormPostJWT = "form_post.jwt"
+	ResponseModeFragmentJWT = "fragment.jwt"
+)
+
+// IsJWTResponseMode returns true if given response mode is one of JARM response modes.
+func IsJWTResponseMode(responseMode string) bool {
+	switch responseMode {
+	case ResponseModeJWT, ResponseModeQueryJWT, ResponseModeFormPostJWT, ResponseModeFragmentJWT:
+		return true
+	}
+	return false
+}
+
+// VerifyAuthorizationResponse verifies JARM "response" JWT and returns authorization response parameters
+// (e.g code, state or error) carried in it.
+// Signature is verified against provider's key set, "iss" must match the provider, "aud" must contain cfg.ClientID and
+// the response must not be expired. If cfg.SupportedSigningAlgs is empty, asymmetric algorithms from provider's
+// authorization_signing_alg_values_supported are allowed, or RS256 if not advertised.
+// See https://openid.net/specs/oauth-v2-jarm.html#name-processing-rules.
+func (c *Client) VerifyAuthorizationResponse(ctx context.Context, cfg VerificationConfig, response string) (url.Values, error) {
+	if response == "" {
+		return nil, errors.New("oidc: missing authorization response JWT")
+	}
+	if len(cfg.SupportedSigningAlgs) == 0 {
+		cfg.SupportedSigningAlgs = defaultSigningAlgs(c.Discovery().AuthorizationSigningAlgValuesSupported)
+	}
+	v := c.Verifier(cfg)
+
+	jws, err := jose.ParseSigned(response)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: malformed authorization response jwt: %v", err)
+	}
+	payload, err := parseJWT(response)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: malformed authorization response jwt: %v", err)
+	}
+
+	var claims struct {
+		Issuer   string      `json:"iss"`
+		Audience Audience    `json:"aud"`
+		Expiry   NumericDate `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("oidc: failed to unmarshal authorization response claims: %v", err)
+	}
+
+	if _, err := v.issuer.validateToken(claims.Issuer); err != nil {
+		return nil, err
+	}
+	if cfg.ClientID == "" {
+		return nil, fmt.Errorf("oidc: Invalid configuration. ClientID must be provided")
+	}
+	if !contains(claims.Audience, cfg.ClientID) {
+		return nil, fmt.Errorf("oidc: expected Audience %q got %q", cfg.ClientID, claims.Audience)
+	}
+
+	now := time.Now
+	if cfg.Now != nil {
+		now = cfg.Now
+	}
+	if claims.Expiry == 0 {
+		return nil, errors.New("oidc: authorization response has no exp claim")
+	}
+	if claims.Expiry.Time().Add(cfg.Leeway).Before(now()) {
+		return nil, fmt.Errorf("oidc: authorization response is expired (Expiry: %v)", claims.Expiry.Time())
+	}
+
+	if err := v.verifySignature(ctx, jws, payload); err != nil {
+		return nil, err
+	}
+
+	var all map[string]interface{}
+	if err := json.Unmarshal(payload, &all); err != nil {
+		return nil, fmt.Errorf("oidc: failed to unmarshal authorization response claims: %v", err)
+	}
+	params := url.Values{}
+	for k, val := range all {
+		if s, ok := val.(string); ok {
+			params.Set(k, s)
+		}
+	}
+	return params, nil
+}
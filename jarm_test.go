@@ -0,0 +1,95 @@
+package oidc
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/bwplotka/go-jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyAuthorizationResponse(t *testing.T) {
+	builder, err := jwt.NewDefaultBuilder()
+	require.NoError(t, err)
+
+	c := &Client{
+		issuer:    issuerValidator{issuer: exampleIssuer},
+		discovery: DiscoveryJSON{Issuer: exampleIssuer, AuthorizationSigningAlgValuesSupported: []string{"none", "RS256"}},
+		keySet:    staticKeySet{builder.PublicJWK()},
+	}
+
+	now := time.Now()
+	newResponse := func(claims map[string]interface{}) string {
+		all := map[string]interface{}{
+			"iss":   exampleIssuer,
+			"aud":   "client1",
+			"exp":   now.Add(1 * time.Minute).Unix(),
+			"code":  "code1",
+			"state": "state1",
+		}
+		for k, v := range claims {
+			if v == nil {
+				delete(all, k)
+				continue
+			}
+			all[k] = v
+		}
+		token, err := builder.JWS().Claims(all).CompactSerialize()
+		require.NoError(t, err)
+		return token
+	}
+
+	params, err := c.VerifyAuthorizationResponse(context.Background(), VerificationConfig{ClientID: "client1"}, newResponse(nil))
+	require.NoError(t, err)
+	assert.Equal(t, url.Values{
+		"iss":   {exampleIssuer},
+		"aud":   {"client1"},
+		"code":  {"code1"},
+		"state": {"state1"},
+	}, params)
+
+	for _, tcase := range []struct {
+		name    string
+		claims  map[string]interface{}
+		wantErr string
+	}{
+		{name: "wrong issuer", claims: map[string]interface{}{"iss": "https://evil.org"}, wantErr: "oidc: id token issued by a different provider"},
+		{name: "wrong audience", claims: map[string]interface{}{"aud": "client2"}, wantErr: "oidc: expected Audience"},
+		{name: "expired", claims: map[string]interface{}{"exp": now.Add(-1 * time.Minute).Unix()}, wantErr: "oidc: authorization response is expired"},
+		{name: "no exp", claims: map[string]interface{}{"exp": nil}, wantErr: "oidc: authorization response has no exp claim"},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			_, err := c.VerifyAuthorizationResponse(context.Background(), VerificationConfig{ClientID: "client1"}, newResponse(tcase.claims))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tcase.wantErr)
+		})
+	}
+
+	// Signed by different key.
+	otherBuilder, err := jwt.NewDefaultBuilder()
+	require.NoError(t, err)
+	forged, err := otherBuilder.JWS().Claims(map[string]interface{}{
+		"iss": exampleIssuer, "aud": "client1", "exp": now.Add(1 * time.Minute).Unix(), "code": "code2", "state": "state1",
+	}).CompactSerialize()
+	require.NoError(t, err)
+	_, err = c.VerifyAuthorizationResponse(context.Background(), VerificationConfig{ClientID: "client1"}, forged)
+	require.Error(t, err)
+
+	// Unsigned, even though "none" is advertised and supported.
+	claims, err := json.Marshal(map[string]interface{}{
+		"iss": exampleIssuer, "aud": "client1", "exp": now.Add(1 * time.Minute).Unix(), "code": "code2", "state": "state1",
+	})
+	require.NoError(t, err)
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." + base64.RawURLEncoding.EncodeToString(claims) + "."
+	_, err = c.VerifyAuthorizationResponse(context.Background(), VerificationConfig{ClientID: "client1", SupportedSigningAlgs: []string{"none"}}, unsigned)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "oidc: unsigned jwt is not allowed")
+
+	_, err = c.VerifyAuthorizationResponse(context.Background(), VerificationConfig{ClientID: "client1"}, "")
+	require.Error(t, err)
+}
@@ -0,0 +1,121 @@
+package oidc
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jxsl13/oidc/xerrors"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+// Default algorithms allowed for encrypted tokens and responses. RSA1_5 is not allowed because of padding oracle
+// attacks.
+var (
+	defaultKeyManagementAlgs = []string{
+		string(jose.RSA_OAEP), string(jose.RSA_OAEP_256),
+		string(jose.ECDH_ES), string(jose.ECDH_ES_A128KW), string(jose.ECDH_ES_A192KW), string(jose.ECDH_ES_A256KW),
+		string(jose.DIRECT),
+	}
+	defaultContentEncryptionAlgs = []string{
+		string(jose.A128GCM), string(jose.A192GCM), string(jose.A256GCM),
+		string(jose.A128CBC_HS256), string(jose.A192CBC_HS384), string(jose.A256CBC_HS512),
+	}
+)
+
+// isJWE returns true if raw token is in JWE compact serialization, which has five parts (JWS has three).
+func isJWE(raw string) bool {
+	return strings.Count(raw, ".") == 4
+}
+
+// decryptJWE decrypts JWE in compact serialization using cfg.DecryptionKeys or, for "dir" algorithm, the key derived
+// from cfg.ClientSecret. Keys with "kid" matching the JWE header are tried, or all keys if JWE has no "kid".
+// Key management and content encryption algorithms need to be allowed by cfg.
+func decryptJWE(raw string, cfg VerificationConfig) ([]byte, error) {
+	jwe, err := jose.ParseEncrypted(raw)
+	if err != nil {
+		return nil, fmt.Errorf("malformed jwe: %v", err)
+	}
+
+	alg := jwe.Header.Algorithm
+	algs := cfg.SupportedKeyManagementAlgs
+	if len(algs) == 0 {
+		algs = defaultKeyManagementAlgs
+	}
+	if !contains(algs, alg) {
+		return nil, fmt.Errorf("key management algorithm %q is not supported, expected %q", alg, algs)
+	}
+	enc, _ := jwe.Header.ExtraHeaders["enc"].(string)
+	encs := cfg.SupportedContentEncryptionAlgs
+	if len(encs) == 0 {
+		encs = defaultContentEncryptionAlgs
+	}
+	if !contains(encs, enc) {
+		return nil, fmt.Errorf("content encryption algorithm %q is not supported, expected %q", enc, encs)
+	}
+
+	keys := cfg.DecryptionKeys
+	if alg == string(jose.DIRECT) && cfg.ClientSecret != "" {
+		key, err := clientSecretKey(cfg.ClientSecret, jose.ContentEncryption(enc))
+		if err != nil {
+			return nil, err
+		}
+		keys = append([]jose.JSONWebKey{{Key: key}}, keys...)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("response is encrypted, but no DecryptionKeys are configured")
+	}
+
+	xerr := xerrors.New()
+	for _, key := range keys {
+		if jwe.Header.KeyID != "" && key.KeyID != "" && key.KeyID != jwe.Header.KeyID {
+			continue
+		}
+		plaintext, err := jwe.Decrypt(key)
+		if err != nil {
+			xerr.Add(err)
+			continue
+		}
+		return plaintext, nil
+	}
+	if err := xerr.ErrorOrNil(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("no decryption key matches key ID %q", jwe.Header.KeyID)
+}
+
+// clientSecretKey derives symmetric key for given content encryption algorithm from the client secret: left-most bits
+// of SHA-256, SHA-384 or SHA-512 hash of the secret, depending on the key size (OIDC Core 10.2).
+func clientSecretKey(secret string, enc jose.ContentEncryption) ([]byte, error) {
+	var size int
+	switch enc {
+	case jose.A128GCM:
+		size = 16
+	case jose.A192GCM:
+		size = 24
+	case jose.A256GCM, jose.A128CBC_HS256:
+		size = 32
+	case jose.A192CBC_HS384:
+		size = 48
+	case jose.A256CBC_HS512:
+		size = 64
+	default:
+		return nil, fmt.Errorf("cannot derive key from client secret for %q content encryption algorithm", enc)
+	}
+
+	var sum []byte
+	switch {
+	case size <= sha256.Size:
+		h := sha256.Sum256([]byte(secret))
+		sum = h[:]
+	case size <= sha512.Size384:
+		h := sha512.Sum384([]byte(secret))
+		sum = h[:]
+	default:
+		h := sha512.Sum512([]byte(secret))
+		sum = h[:]
+	}
+	return sum[:size], nil
+}
@@ -0,0 +1,115 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"testing"
+	"time"
+
+	"github.com/bwplotka/go-jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+func TestVerify_EncryptedIDToken(t *testing.T) {
+	builder, err := jwt.NewDefaultBuilder()
+	require.NoError(t, err)
+
+	c := &Client{
+		issuer:    issuerValidator{issuer: exampleIssuer},
+		discovery: DiscoveryJSON{Issuer: exampleIssuer},
+		keySet:    staticKeySet{builder.PublicJWK()},
+	}
+
+	signed, err := builder.JWS().Claims(map[string]interface{}{
+		"iss": exampleIssuer,
+		"aud": "client1",
+		"sub": "subject1",
+		"exp": time.Now().Add(1 * time.Minute).Unix(),
+	}).CompactSerialize()
+	require.NoError(t, err)
+
+	encrypt := func(enc jose.ContentEncryption, alg jose.KeyAlgorithm, key interface{}, keyID string) string {
+		encrypter, err := jose.NewEncrypter(enc, jose.Recipient{Algorithm: alg, Key: key, KeyID: keyID}, (&jose.EncrypterOptions{}).WithContentType("JWT"))
+		require.NoError(t, err)
+		jwe, err := encrypter.Encrypt([]byte(signed))
+		require.NoError(t, err)
+		token, err := jwe.CompactSerialize()
+		require.NoError(t, err)
+		return token
+	}
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	secretKey := sha256.Sum256([]byte("secret1"))
+
+	cfg := VerificationConfig{
+		ClientID:     "client1",
+		ClientSecret: "secret1",
+		DecryptionKeys: []jose.JSONWebKey{
+			{Key: rsaKey, KeyID: "rsa1", Use: "enc"},
+			{Key: ecKey, KeyID: "ec1", Use: "enc"},
+		},
+	}
+
+	for _, tcase := range []struct {
+		name    string
+		token   string
+		cfg     func(VerificationConfig) VerificationConfig
+		wantErr string
+	}{
+		{name: "RSA-OAEP", token: encrypt(jose.A128GCM, jose.RSA_OAEP, &rsaKey.PublicKey, "rsa1")},
+		{name: "RSA-OAEP-256", token: encrypt(jose.A256CBC_HS512, jose.RSA_OAEP_256, &rsaKey.PublicKey, "rsa1")},
+		{name: "ECDH-ES", token: encrypt(jose.A256GCM, jose.ECDH_ES, &ecKey.PublicKey, "ec1")},
+		{name: "ECDH-ES+A128KW without kid", token: encrypt(jose.A128GCM, jose.ECDH_ES_A128KW, &ecKey.PublicKey, "")},
+		{name: "dir with client secret", token: encrypt(jose.A128CBC_HS256, jose.DIRECT, secretKey[:], "")},
+		{
+			name:    "RSA1_5 not allowed",
+			token:   encrypt(jose.A128GCM, jose.RSA1_5, &rsaKey.PublicKey, "rsa1"),
+			wantErr: `oidc: failed to decrypt id token: key management algorithm "RSA1_5" is not supported`,
+		},
+		{
+			name:  "enc not allowed",
+			token: encrypt(jose.A128CBC_HS256, jose.RSA_OAEP, &rsaKey.PublicKey, "rsa1"),
+			cfg: func(cfg VerificationConfig) VerificationConfig {
+				cfg.SupportedContentEncryptionAlgs = []string{"A256GCM"}
+				return cfg
+			},
+			wantErr: `oidc: failed to decrypt id token: content encryption algorithm "A128CBC-HS256" is not supported`,
+		},
+		{
+			name:    "wrong client secret",
+			token:   encrypt(jose.A128CBC_HS256, jose.DIRECT, secretKey[:], ""),
+			cfg:     func(cfg VerificationConfig) VerificationConfig { cfg.ClientSecret = "secret2"; return cfg },
+			wantErr: "oidc: failed to decrypt id token",
+		},
+		{
+			name:    "no decryption keys",
+			token:   encrypt(jose.A128GCM, jose.RSA_OAEP, &rsaKey.PublicKey, "rsa1"),
+			cfg:     func(VerificationConfig) VerificationConfig { return VerificationConfig{ClientID: "client1"} },
+			wantErr: "oidc: failed to decrypt id token: response is encrypted, but no DecryptionKeys are configured",
+		},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			cfg := cfg
+			if tcase.cfg != nil {
+				cfg = tcase.cfg(cfg)
+			}
+			idToken, err := c.Verifier(cfg).Verify(context.Background(), tcase.token)
+			if tcase.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tcase.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "subject1", idToken.Subject)
+		})
+	}
+}
@@ -60,12 +60,13 @@ func (r *cachedKeySet) Keys(ctx context.Context) ([]jose.JSONWebKey, error) {
 
 }
 
-func newRemoteKeySet(jwksURL string) keySet {
-	return &remoteKeySet{jwksURL: jwksURL}
+func newRemoteKeySet(jwksURL string, doRequest func(context.Context, *http.Request) (*http.Response, error)) keySet {
+	return &remoteKeySet{jwksURL: jwksURL, doRequest: doRequest}
 }
 
 type remoteKeySet struct {
-	jwksURL string
+	jwksURL   string
+	doRequest func(context.Context, *http.Request) (*http.Response, error)
 
 	// guard all other fields
 	mutex sync.Mutex
@@ -145,7 +146,7 @@ func (r *remoteKeySet) updateKeys(ctx context.Context) error {
 		return fmt.Errorf("oidc: can't create request: %v", err)
 	}
 
-	resp, err := doRequest(ctx, req)
+	resp, err := r.doRequest(ctx, req)
 	if err != nil {
 		return fmt.Errorf("oidc: get keys failed %v", err)
 	}
@@ -10,16 +10,23 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/jxsl13/oidc"
 	"github.com/pkg/browser"
 )
 
 const (
-	codeParam  = "code"
-	stateParam = "state"
+	codeParam        = "code"
+	stateParam       = "state"
+	responseParam    = "response"
+	idTokenParam     = "id_token"
+	accessTokenParam = "access_token"
+	tokenTypeParam   = "token_type"
+	expiresInParam   = "expires_in"
 
 	errParam     = "error"
 	errDescParam = "error_description"
@@ -48,6 +55,17 @@ type callbackResponse struct {
 type callbackRequest struct {
 	ctx           context.Context
 	expectedState string
+	// exchangeParams are extra URL values passed to the code exchange (e.g PKCE code verifier).
+	exchangeParams url.Values
+	// logout is set if post-logout redirect is expected instead of auth code callback.
+	logout bool
+	// responseType is the requested response type. Empty means "code".
+	responseType string
+	// responseMode is the requested response mode. For JARM modes, callback parameters are taken only from verified
+	// response JWT.
+	responseMode string
+	// nonce is the nonce sent in auth request. It is checked in ID token returned from auth endpoint.
+	nonce string
 
 	cfg    oidc.Config
 	client *oidc.Client
@@ -107,7 +125,9 @@ func NewReuseServer(pattern string, listenAddress string, mux *http.ServeMux) *C
 	return s
 }
 
-// callbackHandler handles redirect from OIDC provider with either code or error parameters.
+// callbackHandler handles redirect from OIDC provider with either code (and tokens for hybrid and implicit flows) or
+// error parameters. For JARM response modes, the parameters are extracted from verified response JWT. Parameters can
+// be passed in query or POST form. For fragment response mode, callback page that posts fragment back is served first.
 // If none callback is expected it will return error.
 // In case of valid code with corresponded state it will perform token exchange with OIDC provider.
 // Any message is propagated via Go channel if the callback was expected.
@@ -119,6 +139,12 @@ func (s *CallbackServer) callbackHandler(w http.ResponseWriter, r *http.Request)
 		w.Write([]byte("Did not expect OIDC callback"))
 		return
 	}
+	if s.callbackReq.fragmentResponse() && r.Method == http.MethodGet && r.URL.RawQuery == "" {
+		// Browser does not send fragment to the server. Serve page that posts it back and keep waiting for it.
+		s.callbackReqMu.Unlock()
+		FragmentCallbackResponse(w, r)
+		return
+	}
 	defer func() {
 		s.callbackReq = nil
 		s.callbackReqMu.Unlock()
@@ -131,7 +157,26 @@ func (s *CallbackServer) callbackHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	code, state, err := parseCallbackRequest(r.Form)
+	if s.callbackReq.logout {
+		s.logoutCallback(w, r)
+		return
+	}
+
+	form := r.Form
+	if oidc.IsJWTResponseMode(s.callbackReq.responseMode) {
+		form, err = s.callbackReq.client.VerifyAuthorizationResponse(
+			mergeContexts(r.Context(), s.callbackReq.ctx),
+			oidc.VerificationConfig{ClientID: s.callbackReq.cfg.ClientID},
+			r.Form.Get(responseParam),
+		)
+		if err != nil {
+			err := fmt.Errorf("Failed to verify authorization response. Err: %v", err)
+			s.errRespond(w, r, err)
+			return
+		}
+	}
+
+	state, err := parseCallbackRequest(form)
 	if err != nil {
 		s.errRespond(w, r, err)
 		return
@@ -144,7 +189,7 @@ func (s *CallbackServer) callbackHandler(w http.ResponseWriter, r *http.Request)
 	}
 
 	ctx := mergeContexts(r.Context(), s.callbackReq.ctx)
-	oidcToken, err := s.callbackReq.client.Exchange(ctx, s.callbackReq.cfg, code)
+	oidcToken, err := s.callbackReq.token(ctx, form)
 	if err != nil {
 		s.errRespond(w, r, err)
 		return
@@ -161,23 +206,131 @@ func (s *CallbackServer) callbackHandler(w http.ResponseWriter, r *http.Request)
 	return
 }
 
-func parseCallbackRequest(form url.Values) (code string, state string, err error) {
+// logoutCallback handles post-logout redirect from OIDC provider. It carries only state parameter.
+func (s *CallbackServer) logoutCallback(w http.ResponseWriter, r *http.Request) {
+	if state := r.Form.Get(stateParam); state != s.callbackReq.expectedState {
+		err := fmt.Errorf("Invalid state parameter. Got %s, expected: %s", state, s.callbackReq.expectedState)
+		s.errRespond(w, r, err)
+		return
+	}
+
+	OKLogoutCallbackResponse(w, r)
+	select {
+	case <-s.callbackReq.ctx.Done():
+	case s.callbackCh <- &callbackResponse{}:
+	}
+}
+
+func parseCallbackRequest(form url.Values) (state string, err error) {
 	state = form.Get(stateParam)
 	if state == "" {
-		return "", "", errors.New("User session error. No state parameter.")
+		return "", errors.New("User session error. No state parameter.")
 	}
 
 	if errorCode := form.Get(errParam); errorCode != "" {
 		// Got error from provider. Passing through.
-		return "", "", fmt.Errorf("Got error from provider: %s Desc: %s", errorCode, form.Get(errDescParam))
+		return "", fmt.Errorf("Got error from provider: %s Desc: %s", errorCode, form.Get(errDescParam))
+	}
+	return state, nil
+}
+
+// fragmentResponse returns true if provider is expected to pass callback parameters in URL fragment.
+func (r *callbackRequest) fragmentResponse() bool {
+	switch r.responseMode {
+	case oidc.ResponseModeFragment, oidc.ResponseModeFragmentJWT:
+		return true
+	case "", oidc.ResponseModeJWT:
+		return oidc.DefaultResponseMode(r.responseType) == oidc.ResponseModeFragment
+	}
+	return false
+}
+
+// token obtains token for callback parameters. ID token returned from auth endpoint is verified (including nonce and
+// c_hash) before the code is exchanged. Without code (implicit flow), token is taken from the parameters directly.
+// In hybrid flows returning access token from auth endpoint ("code token", "code id_token token"), that access token
+// is checked against at_hash of the front-channel ID token if there is one, but it is discarded afterwards: the token
+// from the token endpoint is returned.
+func (r *callbackRequest) token(ctx context.Context, form url.Values) (*oidc.Token, error) {
+	code := form.Get(codeParam)
+	if code == "" && (r.responseType == "" || oidc.ResponseTypeContains(r.responseType, oidc.ResponseTypeCode)) {
+		return nil, errors.New("Missing code token.")
+	}
+
+	var frontChannelIDToken *oidc.IDToken
+	if oidc.ResponseTypeContains(r.responseType, oidc.ResponseTypeIDToken) {
+		rawIDToken := form.Get(idTokenParam)
+		if rawIDToken == "" {
+			return nil, errors.New("Missing id_token.")
+		}
+
+		var err error
+		frontChannelIDToken, err = r.client.VerifyFrontChannelIDToken(ctx, oidc.VerificationConfig{
+			ClientID:   r.cfg.ClientID,
+			ClaimNonce: r.nonce,
+		}, rawIDToken, code)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to verify ID token from auth endpoint. Err: %v", err)
+		}
 	}
 
-	code = form.Get(codeParam)
 	if code == "" {
-		return "", "", errors.New("Missing code token.")
+		token, err := implicitToken(form)
+		if err != nil {
+			return nil, err
+		}
+		// Access token from auth endpoint needs to be bound to the ID token (OIDC Core 3.2.2.9).
+		if frontChannelIDToken != nil {
+			if err := frontChannelIDToken.VerifyAccessToken(token.AccessToken); err != nil {
+				return nil, fmt.Errorf("Failed to verify access token from auth endpoint. Err: %v", err)
+			}
+		}
+		return token, nil
+	}
+
+	if frontChannelIDToken != nil && form.Get(accessTokenParam) != "" {
+		// at_hash is required for access token issued together with ID token from auth endpoint (OIDC Core 3.3.2.11).
+		if err := frontChannelIDToken.VerifyAccessToken(form.Get(accessTokenParam)); err != nil {
+			return nil, fmt.Errorf("Failed to verify access token from auth endpoint. Err: %v", err)
+		}
 	}
 
-	return code, state, nil
+	token, err := r.client.Exchange(ctx, r.cfg, code, r.exchangeParams)
+	if err != nil {
+		return nil, err
+	}
+	if frontChannelIDToken != nil && token.IDToken != "" {
+		// ID token from token endpoint needs to be issued to the same user (OIDC Core 3.3.3.6).
+		idToken, err := r.client.Verifier(oidc.VerificationConfig{ClientID: r.cfg.ClientID}).Verify(ctx, token.IDToken)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to verify ID token from token endpoint. Err: %v", err)
+		}
+		if idToken.Issuer != frontChannelIDToken.Issuer || idToken.Subject != frontChannelIDToken.Subject {
+			return nil, errors.New("ID token from token endpoint does not match ID token from auth endpoint.")
+		}
+	}
+	return token, nil
+}
+
+// implicitToken returns token passed in callback parameters in implicit flow.
+func implicitToken(form url.Values) (*oidc.Token, error) {
+	token := &oidc.Token{
+		AccessToken: form.Get(accessTokenParam),
+		IDToken:     form.Get(idTokenParam),
+	}
+	if token.AccessToken == "" {
+		return nil, errors.New("Missing access_token.")
+	}
+	if tokenType := form.Get(tokenTypeParam); !strings.EqualFold(tokenType, "bearer") {
+		return nil, fmt.Errorf("Unsupported token type %q.", tokenType)
+	}
+	if expiresIn := form.Get(expiresInParam); expiresIn != "" {
+		seconds, err := strconv.Atoi(expiresIn)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid expires_in parameter. Err: %v", err)
+		}
+		token.AccessTokenExpiry = time.Now().Add(time.Duration(seconds) * time.Second)
+	}
+	return token, nil
 }
 
 // OKCallbackResponse is package wide function variable that returns HTTP response on successful OIDC `code` flow.
@@ -186,6 +339,19 @@ var OKCallbackResponse = func(w http.ResponseWriter, _ *http.Request) {
 	w.Write([]byte(DefaultOkCallbackHTML))
 }
 
+// FragmentCallbackResponse is package wide function variable that returns HTTP response with page that posts URL
+// fragment parameters back to the callback server (for implicit and hybrid flows with fragment response mode).
+var FragmentCallbackResponse = func(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(DefaultFragmentCallbackHTML))
+}
+
+// OKLogoutCallbackResponse is package wide function variable that returns HTTP response on successful post-logout redirect.
+var OKLogoutCallbackResponse = func(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(DefaultOkLogoutCallbackHTML))
+}
+
 // ErrCallbackResponse is package wide function variable that returns HTTP response on failed OIDC `code` flow.
 // Note that, by default we don't want user to see anything wrong on browser side. All errors are propagated to command.
 // If it is required otherwise, override this function.
@@ -14,6 +14,45 @@ var (
 	    </script>
 	</body>
 </html>
+`
+	// DefaultOkLogoutCallbackHTML will be shown when the browser logout flow succeeds.
+	DefaultOkLogoutCallbackHTML = `
+<html>
+	<head>
+	</head>
+	<body >
+	    Successfully logged out.</br>
+	    This page will be closed in a few seconds.
+	    <script>
+		    setTimeout(function() {window.close()}, 5000);
+	    </script>
+	</body>
+</html>
+`
+	// DefaultFragmentCallbackHTML is served on callback when parameters are expected in URL fragment, which browsers don't
+	// send to the server. It posts the fragment parameters back to the callback URL.
+	DefaultFragmentCallbackHTML = `
+<html>
+	<head>
+	</head>
+	<body >
+	    Finishing authorization...
+	    <form id="fragment" method="post"></form>
+	    <script>
+		    var form = document.getElementById("fragment");
+		    form.action = window.location.pathname;
+		    var params = new URLSearchParams(window.location.hash.substring(1));
+		    params.forEach(function(value, key) {
+			    var input = document.createElement("input");
+			    input.type = "hidden";
+			    input.name = key;
+			    input.value = value;
+			    form.appendChild(input);
+		    });
+		    form.submit();
+	    </script>
+	</body>
+</html>
 `
 	// DefaultErrCallbackHTML is shown when the browser lofin flow fails.
 	DefaultErrCallbackHTML = `
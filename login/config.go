@@ -5,14 +5,48 @@ import (
 	"net/url"
 
 	"github.com/ghodss/yaml"
+	"github.com/jxsl13/oidc"
 )
 
 // Config is a login configuration. It does not contain oidc configuration.
 type Config struct {
 	NonceCheck bool `json:"include_nonce"`
+	// PKCE enables Proof Key for Code Exchange (RFC 7636) in the auth code flow. Recommended for public clients that
+	// cannot keep the client secret.
+	PKCE bool `json:"pkce"`
+	// PKCEMethod is a PKCE code challenge method. Defaults to S256.
+	PKCEMethod string `json:"pkce_method"`
 	// ExtraAuthRequestParams are extra url params in OIDC auth request.
 	// For example with Google OIDC provider https://accounts.google.com, you can use "access_type=offline".
 	ExtraAuthRequestParams url.Values `json:"extra_auth_request_params"`
+	// ResponseType is passed as response_type in OIDC auth request. Empty means "code" (authorization code flow).
+	// Hybrid ("code id_token", "code token", "code id_token token") and implicit ("id_token token") response types are
+	// supported as well. ID token returned from the auth endpoint is verified (including nonce and c_hash) before the
+	// code is exchanged.
+	ResponseType string `json:"response_type"`
+	// ResponseMode is passed as response_mode in OIDC auth request. Use "jwt", "query.jwt" or "form_post.jwt" (JARM) to
+	// receive code and state in a JWT signed by the provider, which protects the callback against injected parameters.
+	// Use "form_post" to receive parameters in POST body. Empty means provider's default (query for code flow,
+	// fragment otherwise). Fragment values are posted back to the callback server by the callback page.
+	ResponseMode string `json:"response_mode"`
+	// DPoP, if set, is used to obtain DPoP-bound tokens (RFC 9449). It is not cached with the token, so it is attached
+	// again to DPoP-bound tokens loaded from cache. Use prover with persisted key (see oidc.NewDPoPProver), otherwise
+	// cached tokens cannot be used by other processes. Use oidc.Token.AuthorizeRequest to authorize requests.
+	DPoP *oidc.DPoPProver `json:"-"`
+}
+
+func (c Config) responseType() string {
+	if c.ResponseType == "" {
+		return oidc.ResponseTypeCode
+	}
+	return c.ResponseType
+}
+
+func (c Config) pkceMethod() string {
+	if c.PKCEMethod == "" {
+		return oidc.CodeChallengeMethodS256
+	}
+	return c.PKCEMethod
 }
 
 var (
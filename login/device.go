@@ -0,0 +1,111 @@
+package login
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/jxsl13/oidc"
+)
+
+// DevicePrompt presents device authorization details to the user, so the user can finish login on any device with
+// a browser.
+type DevicePrompt func(deviceAuth *oidc.DeviceAuthResponse) error
+
+// DefaultDevicePrompt prints verification URI and user code to stderr.
+func DefaultDevicePrompt(deviceAuth *oidc.DeviceAuthResponse) error {
+	if deviceAuth.VerificationURIComplete != "" {
+		_, err := fmt.Fprintf(os.Stderr, "To login, open %s in a browser and confirm the code: %s\n",
+			deviceAuth.VerificationURIComplete, deviceAuth.UserCode)
+		return err
+	}
+	_, err := fmt.Fprintf(os.Stderr, "To login, open %s in a browser and enter the code: %s\n",
+		deviceAuth.VerificationURI, deviceAuth.UserCode)
+	return err
+}
+
+// NewDeviceTokenSource constructs OIDCTokenSource that performs OAuth 2.0 Device Authorization Grant (RFC 8628) instead
+// of browser login. It does not need browser nor CallbackServer on the same machine, so it works on headless systems
+// (e.g over SSH or inside containers).
+// Cached tokens are reused and refreshed in the same way as for NewOIDCTokenSource. If prompt is nil, DefaultDevicePrompt is used.
+// NonceCheck is ignored, since there is no authentication request to pass nonce in.
+func NewDeviceTokenSource(ctx context.Context, logger *log.Logger, cfg Config, cache Cache, prompt DevicePrompt) (src oidc.TokenSource, clearIDToken func() error, err error) {
+	if cache == nil {
+		return nil, nil, errors.New("cache cannot be nil")
+	}
+
+	oidcClient, err := oidc.NewClient(ctx, cache.Config().Provider)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize OIDC client. Err: %v", err)
+	}
+
+	if oidcClient.Discovery().DeviceAuthorizationURL == "" || !oidcClient.Discovery().SupportsGrantType(oidc.GrantTypeDeviceCode) {
+		return nil, nil, fmt.Errorf("provider %s does not support device authorization grant", cache.Config().Provider)
+	}
+
+	if prompt == nil {
+		prompt = DefaultDevicePrompt
+	}
+
+	s := &OIDCTokenSource{
+		logger: logger,
+		cfg:    cfg,
+
+		oidcClient: oidcClient,
+		cache:      cache,
+
+		devicePrompt: prompt,
+	}
+
+	reuseTokenSource, reset := oidc.NewReuseTokenSourceWithDebugLogger(logger, nil, s)
+	// Our clear ID token function needs to reset reuse token to make sense.
+	return reuseTokenSource, s.clearIDToken(reset), nil
+}
+
+// newDeviceToken performs device authorization grant. It prompts user with verification URI and user code and polls
+// Provider token endpoint until user finishes login on other device.
+func (s *OIDCTokenSource) newDeviceToken(ctx context.Context) (*oidc.Token, error) {
+	s.logger.Print("Debug: Performing device authorization grant to obtain entirely new OIDC token.")
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	cfg := s.getOIDCConfig()
+	deviceAuth, err := s.oidcClient.DeviceAuth(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.devicePrompt(deviceAuth)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: Failed to prompt user. Please open %s in browser and enter the code %s. Err: %v",
+			deviceAuth.VerificationURI, deviceAuth.UserCode, err)
+	}
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt)
+	defer signal.Stop(quit)
+	go func() {
+		select {
+		case <-quit:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	token, err := s.oidcClient.ExchangeDeviceCode(ctx, cfg, deviceAuth)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: Device authorization error: %v", err)
+	}
+
+	// Device flow has no authentication request, so there is no nonce to check.
+	s.nonce = ""
+	err = s.cache.SaveToken(token)
+	if err != nil {
+		s.logger.Printf("Warn: Cannot cache token. Err: %v", err)
+	}
+	return token, nil
+}
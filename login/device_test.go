@@ -0,0 +1,75 @@
+package login
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/jxsl13/oidc"
+)
+
+func (s *TokenSourceTestSuite) Test_CacheEmpty_NewDeviceToken_OK() {
+	defer func() {
+		s.oidcSource.devicePrompt = nil
+		s.oidcSource.nonce = testNonce
+	}()
+	s.cache.On("Token").Return(nil, nil)
+	s.cache.On("SaveToken", &testToken).Return(nil)
+
+	s.provider.MockDeviceAuthCall(http.StatusOK, `{
+		"device_code": "device1",
+		"user_code": "USER-CODE",
+		"verification_uri": "https://example.com/device",
+		"expires_in": 60,
+		"interval": 1
+	}`)
+	s.provider.MockTokenCall(http.StatusBadRequest, `{"error": "authorization_pending"}`)
+	b, err := json.Marshal(testToken)
+	s.Require().NoError(err)
+	s.provider.MockTokenCall(http.StatusOK, string(b))
+
+	var prompted *oidc.DeviceAuthResponse
+	s.oidcSource.devicePrompt = func(deviceAuth *oidc.DeviceAuthResponse) error {
+		prompted = deviceAuth
+		return nil
+	}
+
+	token, err := s.oidcSource.OIDCToken(context.Background())
+	s.Require().NoError(err)
+
+	s.Equal(testToken, *token)
+	s.Require().NotNil(prompted)
+	s.Equal("USER-CODE", prompted.UserCode)
+	s.Equal("https://example.com/device", prompted.VerificationURI)
+	s.Empty(s.oidcSource.nonce)
+
+	s.cache.AssertExpectations(s.T())
+	s.Len(s.provider.ExpectedRequests, 0)
+}
+
+func (s *TokenSourceTestSuite) Test_CacheEmpty_NewDeviceToken_AccessDenied() {
+	defer func() {
+		s.oidcSource.devicePrompt = nil
+	}()
+	s.cache.On("Token").Return(nil, nil)
+
+	s.provider.MockDeviceAuthCall(http.StatusOK, `{
+		"device_code": "device1",
+		"user_code": "USER-CODE",
+		"verification_url": "https://example.com/device",
+		"expires_in": 60,
+		"interval": 1
+	}`)
+	s.provider.MockTokenCall(http.StatusBadRequest, `{"error": "access_denied"}`)
+
+	s.oidcSource.devicePrompt = func(deviceAuth *oidc.DeviceAuthResponse) error {
+		s.Equal("https://example.com/device", deviceAuth.VerificationURI)
+		return nil
+	}
+
+	_, err := s.oidcSource.OIDCToken(context.Background())
+	s.Require().Error(err)
+
+	s.cache.AssertExpectations(s.T())
+	s.Len(s.provider.ExpectedRequests, 0)
+}
@@ -95,3 +95,12 @@ func (c *Cache) SaveToken(token *oidc.Token) error {
 func (c *Cache) Config() login.OIDCConfig {
 	return c.cfg
 }
+
+// DeleteToken removes cached token file.
+func (c *Cache) DeleteToken() error {
+	err := os.Remove(filepath.Join(c.storePath, c.tokenCacheFileName()))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("Failed to remove cached token. Err: %v", err)
+	}
+	return nil
+}
@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/jxsl13/oidc"
+	"github.com/jxsl13/oidc/xerrors"
 )
 
 //go:generate mockery -name Cache -case underscore -inpkg
@@ -24,6 +25,12 @@ type Cache interface {
 	Config() OIDCConfig
 }
 
+// TokenDeleter is an optional Cache extension that is able to remove cached token entirely.
+// If Cache does not implement it, Logout saves empty token instead.
+type TokenDeleter interface {
+	DeleteToken() error
+}
+
 // OIDCTokenSource implements `oidc.TokenSource` interface to perform oidc-browser-dance.
 // It caches fetched tokens in provided TokenCache e.g on disk or in k8s config.
 type OIDCTokenSource struct {
@@ -36,9 +43,16 @@ type OIDCTokenSource struct {
 	cache Cache
 	nonce string
 
-	callbackSrv  *CallbackServer
-	openBrowser  func(string) error
-	genRandToken func() string
+	callbackSrv     *CallbackServer
+	openBrowser     func(string) error
+	genRandToken    func() string
+	genCodeVerifier func() string
+
+	// devicePrompt is set only for device authorization grant. In this case it is used instead of browser login.
+	devicePrompt DevicePrompt
+
+	// resetReuseTS resets the reuse token source wrapping this source (if any), so it does not hold stale token.
+	resetReuseTS func()
 
 	mu sync.Mutex
 }
@@ -48,13 +62,51 @@ type OIDCTokenSource struct {
 // If the loginServer is nil, login is disabled.
 // We are making OIDC Connect request in constructor (with context ctx) to make sure oidc works.
 func NewOIDCTokenSource(ctx context.Context, logger *log.Logger, cfg Config, cache Cache, callbackSrv *CallbackServer) (src oidc.TokenSource, clearIDToken func() error, err error) {
+	src, clearIDToken, _, err = NewOIDCTokenSourceWithLogout(ctx, logger, cfg, cache, callbackSrv)
+	return src, clearIDToken, err
+}
+
+// NewOIDCTokenSourceWithLogout is like NewOIDCTokenSource, but additionally returns logout function that
+// performs RP-initiated logout (see OIDCTokenSource.Logout).
+func NewOIDCTokenSourceWithLogout(ctx context.Context, logger *log.Logger, cfg Config, cache Cache, callbackSrv *CallbackServer) (src oidc.TokenSource, clearIDToken func() error, logout func(context.Context) error, err error) {
 	if cache == nil {
-		return nil, nil, errors.New("cache cannot be nil")
+		return nil, nil, nil, errors.New("cache cannot be nil")
 	}
 
 	oidcClient, err := oidc.NewClient(ctx, cache.Config().Provider)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to initialize OIDC client. Err: %v", err)
+		return nil, nil, nil, fmt.Errorf("failed to initialize OIDC client. Err: %v", err)
+	}
+
+	discovery := oidcClient.Discovery()
+	switch cfg.responseType() {
+	case oidc.ResponseTypeCode, oidc.ResponseTypeCodeIDToken, oidc.ResponseTypeCodeToken, oidc.ResponseTypeCodeIDTokenToken,
+		oidc.ResponseTypeIDTokenToken:
+	default:
+		// Login needs both access token and ID token.
+		return nil, nil, nil, fmt.Errorf("response type %q is not supported by login", cfg.ResponseType)
+	}
+	if !discovery.SupportsResponseType(cfg.responseType()) {
+		return nil, nil, nil, fmt.Errorf("provider does not support %q response type. Supported response types: %v", cfg.responseType(), discovery.ResponseTypesSupported)
+	}
+
+	switch cfg.ResponseMode {
+	case "", oidc.ResponseModeQuery, oidc.ResponseModeFragment, oidc.ResponseModeFormPost,
+		oidc.ResponseModeJWT, oidc.ResponseModeQueryJWT, oidc.ResponseModeFormPostJWT, oidc.ResponseModeFragmentJWT:
+	default:
+		return nil, nil, nil, fmt.Errorf("response mode %q is not supported by callback server", cfg.ResponseMode)
+	}
+	if cfg.ResponseMode != "" && !discovery.SupportsResponseMode(cfg.ResponseMode) {
+		return nil, nil, nil, fmt.Errorf("provider does not support %q response mode. Supported: %v", cfg.ResponseMode, discovery.ResponseModesSupported)
+	}
+
+	if cfg.PKCE {
+		if _, err := oidc.NewPKCE(cfg.pkceMethod()); err != nil {
+			return nil, nil, nil, err
+		}
+		if !discovery.SupportsCodeChallengeMethod(cfg.pkceMethod()) {
+			return nil, nil, nil, fmt.Errorf("provider does not support %q PKCE method. Supported: %v", cfg.pkceMethod(), discovery.CodeChallengeMethodsSupported)
+		}
 	}
 
 	s := &OIDCTokenSource{
@@ -64,9 +116,10 @@ func NewOIDCTokenSource(ctx context.Context, logger *log.Logger, cfg Config, cac
 		oidcClient: oidcClient,
 		cache:      cache,
 
-		callbackSrv:  callbackSrv,
-		openBrowser:  openBrowser,
-		genRandToken: rand128Bits,
+		callbackSrv:     callbackSrv,
+		openBrowser:     openBrowser,
+		genRandToken:    rand128Bits,
+		genCodeVerifier: oidc.NewCodeVerifier,
 	}
 
 	if cfg.NonceCheck {
@@ -74,8 +127,9 @@ func NewOIDCTokenSource(ctx context.Context, logger *log.Logger, cfg Config, cac
 	}
 
 	reuseTokenSource, reset := oidc.NewReuseTokenSourceWithDebugLogger(logger, nil, s)
+	s.resetReuseTS = reset
 	// Our clear ID token function needs to reset reuse token to make sense.
-	return reuseTokenSource, s.clearIDToken(reset), nil
+	return reuseTokenSource, s.clearIDToken(reset), s.Logout, nil
 }
 
 func (s *OIDCTokenSource) clearIDToken(resetTS func()) func() error {
@@ -110,6 +164,7 @@ func (s *OIDCTokenSource) getOIDCConfig() oidc.Config {
 		ClientID:     cfg.ClientID,
 		ClientSecret: cfg.ClientSecret,
 		Scopes:       cfg.Scopes,
+		DPoP:         s.cfg.DPoP,
 	}
 	return oidcConfig
 }
@@ -121,6 +176,7 @@ func (s *OIDCTokenSource) getOIDCConfigWithRedirectURL(redirectURL string) oidc.
 		ClientSecret: cfg.ClientSecret,
 		Scopes:       cfg.Scopes,
 		RedirectURL:  redirectURL,
+		DPoP:         s.cfg.DPoP,
 	}
 	return oidcConfig
 }
@@ -135,7 +191,10 @@ func (s *OIDCTokenSource) OIDCToken(ctx context.Context) (*oidc.Token, error) {
 	if err != nil {
 		s.logger.Printf("Warn: Failed to get cached token or token is invalid. Err: %v", err)
 	} else if cachedToken != nil {
-		err = cachedToken.IsValid(ctx, s.Verifier())
+		err = s.attachDPoP(cachedToken)
+		if err == nil {
+			err = cachedToken.IsValidAndBound(ctx, s.Verifier())
+		}
 		if err == nil {
 			// Successfully retrieved a non-expired cached token and only if we have ID token as well.
 			return cachedToken, nil
@@ -143,17 +202,26 @@ func (s *OIDCTokenSource) OIDCToken(ctx context.Context) (*oidc.Token, error) {
 		s.logger.Printf("Warn: Cached token is not valid. Cause: %v\n", err)
 		if cachedToken.RefreshToken != "" {
 			// Only if we have refresh token, we can refresh NewIDToken.
-			oidcToken, err := s.refreshToken(ctx, cachedToken.RefreshToken)
+			oidcToken, loginRequired, err := s.refreshToken(ctx, cachedToken.RefreshToken)
 			if err == nil {
 				return oidcToken, nil
 			}
+			if !loginRequired {
+				// Temporary problem (e.g network error or provider unavailable). Don't force user to log in again.
+				return nil, fmt.Errorf("Failed to refresh token. Err: %v", err)
+			}
 
 			// Our refresh token expired.
 			s.logger.Printf("Warn: Refresh token expired. Err: %v", err)
 		}
 	}
 	// Our request for access token was denied, either we had no RefreshToken, it was invalid or expired.
-	newToken, err := s.newToken(ctx)
+	var newToken *oidc.Token
+	if s.devicePrompt != nil {
+		newToken, err = s.newDeviceToken(ctx)
+	} else {
+		newToken, err = s.newToken(ctx)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("Failed to obtain new token. Err: %v", err)
 	}
@@ -161,38 +229,56 @@ func (s *OIDCTokenSource) OIDCToken(ctx context.Context) (*oidc.Token, error) {
 	return newToken, nil
 }
 
-// Verifier returns verifier for tokens.
+// attachDPoP attaches configured DPoP prover to DPoP-bound token loaded from cache, since prover is not cached.
+func (s *OIDCTokenSource) attachDPoP(token *oidc.Token) error {
+	if token.TokenType != oidc.TokenTypeDPoP || token.DPoP != nil {
+		return nil
+	}
+	if s.cfg.DPoP == nil {
+		return errors.New("cached token is DPoP-bound, but DPoP is not configured")
+	}
+	token.DPoP = s.cfg.DPoP
+	return nil
+}
+
+// Verifier returns verifier for tokens. ID tokens encrypted with key derived from client secret ("dir") are decrypted.
 func (s *OIDCTokenSource) Verifier() oidc.Verifier {
 	return s.oidcClient.Verifier(oidc.VerificationConfig{
-		ClientID:   s.cache.Config().ClientID,
-		ClaimNonce: s.nonce,
+		ClientID:     s.cache.Config().ClientID,
+		ClientSecret: s.cache.Config().ClientSecret,
+		ClaimNonce:   s.nonce,
 	})
 }
 
-func (s *OIDCTokenSource) refreshToken(ctx context.Context, refreshToken string) (*oidc.Token, error) {
+// refreshToken obtains new token using refresh token. If refresh fails, loginRequired says if new token needs
+// to be obtained by logging in again, which is the case when provider rejects the refresh token with "invalid_grant"
+// or returns unusable token. Failure to fetch provider's keys does not require login.
+func (s *OIDCTokenSource) refreshToken(ctx context.Context, refreshToken string) (token *oidc.Token, loginRequired bool, err error) {
 	s.logger.Printf("Debug: Cached token has none or expired ID token or access token. " +
 		"Try to refresh access token using refresh token.")
 
-	token, err := oidc.NewTokenRefresher(
+	token, err = oidc.NewTokenRefresher(
 		s.oidcClient,
 		s.getOIDCConfig(),
 		refreshToken,
 	).OIDCToken(ctx)
 	if err != nil {
-		return nil, err
+		var tErr *oidc.TokenError
+		return nil, errors.As(err, &tErr) && tErr.Code == oidc.ErrorCodeInvalidGrant, err
 	}
 
-	_, err = s.Verifier().Verify(ctx, token.IDToken)
+	_, err = token.VerifyIDToken(ctx, s.Verifier())
 	if err != nil {
-		return nil, fmt.Errorf("failed to verify idToken from provider. Err: %v", err)
+		var ksErr *oidc.KeySetError
+		return nil, !errors.As(err, &ksErr), fmt.Errorf("failed to verify idToken from provider. Err: %v", err)
 	}
 
 	if token.AccessToken == "" {
-		return nil, fmt.Errorf("no access token found in token from provider")
+		return nil, true, fmt.Errorf("no access token found in token from provider")
 	}
 
 	if token.IsAccessTokenExpired() {
-		return nil, fmt.Errorf("got expired access token in token from provider")
+		return nil, true, fmt.Errorf("got expired access token in token from provider")
 	}
 
 	err = s.cache.SaveToken(token)
@@ -200,7 +286,7 @@ func (s *OIDCTokenSource) refreshToken(ctx context.Context, refreshToken string)
 		s.logger.Printf("Warn: Cannot cache token. Err: %v", err)
 	}
 
-	return token, nil
+	return token, false, nil
 }
 
 // newToken calls URL to Provider auth endpoint via browser with response type set to `code`. The URL have redirectURL set
@@ -217,33 +303,66 @@ func (s *OIDCTokenSource) newToken(ctx context.Context) (*oidc.Token, error) {
 	state := s.genRandToken()
 	nonce := ""
 	extra := url.Values{}
-	if s.cfg.NonceCheck {
+	// Nonce is required if ID token is returned from auth endpoint.
+	if s.cfg.NonceCheck || oidc.ResponseTypeContains(s.cfg.responseType(), oidc.ResponseTypeIDToken) {
 		nonce = s.genRandToken()
 		extra.Set("nonce", nonce)
 	}
 
+	if s.cfg.ResponseType != "" {
+		extra.Set("response_type", s.cfg.ResponseType)
+	}
+	if s.cfg.ResponseMode != "" {
+		extra.Set("response_mode", s.cfg.ResponseMode)
+	}
+
+	var exchangeParams url.Values
+	if s.cfg.PKCE {
+		// New code verifier is generated for every login and kept only until the code exchange.
+		pkce := &oidc.PKCE{
+			CodeVerifier: s.genCodeVerifier(),
+			Method:       s.cfg.pkceMethod(),
+		}
+		extra.Set("code_challenge", pkce.CodeChallenge())
+		extra.Set("code_challenge_method", pkce.Method)
+		exchangeParams = pkce.ExchangeParams()
+	}
+
 	ctxWithTimeout, cancel := context.WithTimeout(ctx, 1*time.Minute)
 	defer cancel()
 
+	cfg := s.getOIDCConfigWithRedirectURL(s.callbackSrv.RedirectURL())
+	authURL, err := s.authCodeURL(ctxWithTimeout, cfg, state, extra)
+	if err != nil {
+		return nil, err
+	}
+
 	s.callbackSrv.ExpectCallback(&callbackRequest{
-		ctx:           ctxWithTimeout,
-		expectedState: state,
-		client:        s.oidcClient,
-		cfg:           s.getOIDCConfigWithRedirectURL(s.callbackSrv.RedirectURL()),
+		ctx:            ctxWithTimeout,
+		expectedState:  state,
+		exchangeParams: exchangeParams,
+		nonce:          nonce,
+		responseType:   s.cfg.responseType(),
+		responseMode:   s.cfg.ResponseMode,
+		client:         s.oidcClient,
+		cfg:            cfg,
 	})
 
-	authURL := s.oidcClient.AuthCodeURL(s.getOIDCConfigWithRedirectURL(s.callbackSrv.RedirectURL()), state, extra, s.cfg.ExtraAuthRequestParams)
 	s.logger.Printf("Info: Opening browser to access URL: %s", authURL)
-	err := s.openBrowser(authURL)
+	err = s.openBrowser(authURL)
 	if err != nil {
 		return nil, fmt.Errorf("oidc: Failed to open browser. Please open this URL in browser: %s Err: %v", authURL, err)
 	}
 
-	quit := make(chan os.Signal)
+	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt)
+	defer signal.Stop(quit)
 	go func() {
-		<-quit
-		cancel()
+		select {
+		case <-quit:
+			cancel()
+		case <-ctxWithTimeout.Done():
+		}
 	}()
 
 	select {
@@ -255,7 +374,9 @@ func (s *OIDCTokenSource) newToken(ctx context.Context) (*oidc.Token, error) {
 			return nil, fmt.Errorf("oidc: Callback error: %v", msg.err)
 		}
 
-		s.nonce = nonce
+		if s.cfg.NonceCheck {
+			s.nonce = nonce
+		}
 		err = s.cache.SaveToken(msg.token)
 		if err != nil {
 			s.logger.Printf("Warn: Cannot cache token. Err: %v", err)
@@ -265,3 +386,155 @@ func (s *OIDCTokenSource) newToken(ctx context.Context) (*oidc.Token, error) {
 		return nil, fmt.Errorf("oidc Deadline Exceeded: Timed out waiting for token. Please retry the command and open the URL printed above in a browser if it doesn't open automatically")
 	}
 }
+
+// authCodeURL returns URL to provider's auth endpoint. If provider supports Pushed Authorization Requests, the request is
+// pushed first, so the URL carries only the reference to it.
+func (s *OIDCTokenSource) authCodeURL(ctx context.Context, cfg oidc.Config, state string, extra url.Values) (string, error) {
+	if s.oidcClient.Discovery().PushedAuthorizationRequestURL == "" {
+		return s.oidcClient.AuthCodeURL(cfg, state, extra, s.cfg.ExtraAuthRequestParams), nil
+	}
+
+	s.logger.Print("Debug: Pushing authorization request.")
+	par, err := s.oidcClient.PushAuthorizationRequest(ctx, cfg, state, extra, s.cfg.ExtraAuthRequestParams)
+	if err != nil {
+		return "", fmt.Errorf("oidc: Failed to push authorization request. Err: %v", err)
+	}
+	return s.oidcClient.AuthCodeURLWithRequestURI(cfg, par.RequestURI), nil
+}
+
+// Logout performs RP-initiated logout. It revokes cached refresh and access tokens (if provider supports revocation),
+// opens end session URL in the browser and waits for the post-logout redirect on CallbackServer (if provider
+// supports RP-initiated logout) and finally wipes the token from cache.
+// Cache is wiped even if revocation or end session fails, so the user has to log in again in any case.
+func (s *OIDCTokenSource) Logout(ctx context.Context) error {
+	s.mu.Lock()
+	defer func() {
+		if s.resetReuseTS != nil {
+			s.resetReuseTS()
+		}
+		s.mu.Unlock()
+	}()
+
+	token, err := s.cache.Token()
+	if err != nil {
+		s.logger.Printf("Warn: Failed to get cached token. Err: %v", err)
+	}
+
+	xerr := xerrors.New()
+	if token != nil {
+		xerr.Add(s.revokeTokens(ctx, token))
+	}
+
+	var idToken string
+	if token != nil {
+		idToken = token.IDToken
+	}
+	xerr.Add(s.endSession(ctx, idToken))
+
+	if err := s.deleteCachedToken(); err != nil {
+		xerr.Add(fmt.Errorf("failed to wipe cached token. Err: %v", err))
+	}
+
+	if err := xerr.ErrorOrNil(); err != nil {
+		return fmt.Errorf("Logout failed: %v", err)
+	}
+	return nil
+}
+
+// revokeTokens revokes refresh token first (that usually invalidates access tokens as well) and then access token.
+// Access token is revoked even if refresh token revocation fails.
+func (s *OIDCTokenSource) revokeTokens(ctx context.Context, token *oidc.Token) error {
+	if s.oidcClient.Discovery().RevocationURL == "" {
+		s.logger.Print("Debug: Provider does not support token revocation. Skipping.")
+		return nil
+	}
+
+	xerr := xerrors.New()
+	for _, t := range []struct{ token, hint string }{
+		{token: token.RefreshToken, hint: oidc.TokenTypeHintRefreshToken},
+		{token: token.AccessToken, hint: oidc.TokenTypeHintAccessToken},
+	} {
+		if t.token == "" {
+			continue
+		}
+		err := s.oidcClient.Revoke(ctx, s.getOIDCConfig(), t.token, url.Values{"token_type_hint": {t.hint}})
+		if err != nil {
+			xerr.Add(fmt.Errorf("failed to revoke %s. Err: %v", t.hint, err))
+		}
+	}
+	return xerr.ErrorOrNil()
+}
+
+// endSession opens end session URL via browser and waits for post-logout redirect to CallbackServer.
+// In case of none CallbackServer, post_logout_redirect_uri is not passed and we don't wait for redirect.
+func (s *OIDCTokenSource) endSession(ctx context.Context, idToken string) error {
+	if s.oidcClient.Discovery().EndSessionURL == "" {
+		s.logger.Print("Debug: Provider does not support RP-initiated logout. Skipping.")
+		return nil
+	}
+
+	req := oidc.EndSessionRequest{IDTokenHint: idToken}
+	if s.callbackSrv == nil {
+		endSessionURL, err := s.oidcClient.EndSessionURL(s.getOIDCConfig(), req)
+		if err != nil {
+			return err
+		}
+		s.logger.Printf("Info: Opening browser to access URL: %s", endSessionURL)
+		if err := s.openBrowser(endSessionURL); err != nil {
+			return fmt.Errorf("oidc: Failed to open browser. Please open this URL in browser: %s Err: %v", endSessionURL, err)
+		}
+		return nil
+	}
+
+	req.State = s.genRandToken()
+	req.PostLogoutRedirectURL = s.callbackSrv.RedirectURL()
+	endSessionURL, err := s.oidcClient.EndSessionURL(s.getOIDCConfig(), req)
+	if err != nil {
+		return err
+	}
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, 1*time.Minute)
+	defer cancel()
+
+	s.callbackSrv.ExpectCallback(&callbackRequest{
+		ctx:           ctxWithTimeout,
+		expectedState: req.State,
+		logout:        true,
+	})
+
+	s.logger.Printf("Info: Opening browser to access URL: %s", endSessionURL)
+	err = s.openBrowser(endSessionURL)
+	if err != nil {
+		return fmt.Errorf("oidc: Failed to open browser. Please open this URL in browser: %s Err: %v", endSessionURL, err)
+	}
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt)
+	defer signal.Stop(quit)
+	go func() {
+		select {
+		case <-quit:
+			cancel()
+		case <-ctxWithTimeout.Done():
+		}
+	}()
+
+	select {
+	case msg := <-s.callbackSrv.Callback():
+		// Give some time for server to finish request.
+		time.Sleep(200 * time.Millisecond)
+		if msg.err != nil {
+			return fmt.Errorf("oidc: Logout callback error: %v", msg.err)
+		}
+		return nil
+	case <-ctxWithTimeout.Done():
+		return fmt.Errorf("oidc Deadline Exceeded: Timed out waiting for post-logout redirect")
+	}
+}
+
+func (s *OIDCTokenSource) deleteCachedToken() error {
+	if d, ok := s.cache.(TokenDeleter); ok {
+		return d.DeleteToken()
+	}
+	return s.cache.SaveToken(&oidc.Token{})
+}
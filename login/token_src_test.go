@@ -5,9 +5,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
 	"strings"
@@ -16,6 +18,7 @@ import (
 
 	"github.com/jxsl13/oidc"
 	"github.com/jxsl13/oidc/testing"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
@@ -129,6 +132,33 @@ func (s *TokenSourceTestSuite) Test_CacheOK() {
 	s.Len(s.provider.ExpectedRequests, 0)
 }
 
+func (s *TokenSourceTestSuite) Test_CacheOK_DPoPProverAttached() {
+	prover, err := oidc.NewEphemeralDPoPProver()
+	s.Require().NoError(err)
+	s.oidcSource.cfg.DPoP = prover
+	defer func() { s.oidcSource.cfg.DPoP = nil }()
+
+	idToken, jwkSetJSON := s.provider.NewIDToken(testClientID, testSubject, s.oidcSource.nonce)
+	cachedToken := testToken
+	cachedToken.IDToken = idToken
+	cachedToken.TokenType = oidc.TokenTypeDPoP
+	s.cache.On("Token").Return(&cachedToken, nil)
+
+	s.provider.MockPubKeysCall(jwkSetJSON)
+
+	token, err := s.oidcSource.OIDCToken(context.Background())
+	s.Require().NoError(err)
+	s.True(prover == token.DPoP, "configured prover should be attached to cached token")
+
+	r := httptest.NewRequest("GET", "https://resource.org/api", nil)
+	s.Require().NoError(token.AuthorizeRequest(r))
+	s.Equal("DPoP access1", r.Header.Get("Authorization"))
+	s.NotEmpty(r.Header.Get(oidc.DPoPHeader))
+
+	s.cache.AssertExpectations(s.T())
+	s.Len(s.provider.ExpectedRequests, 0)
+}
+
 // stripArgFromURL strips out arg value from URL.
 func stripArgFromURL(arg string, urlToStrip string) (string, error) {
 	var argValue string
@@ -269,6 +299,391 @@ func (s *TokenSourceTestSuite) Test_CacheEmpty_NewToken_OKCallback_ExtraAuthPara
 	s.Len(s.provider.ExpectedRequests, 0)
 }
 
+func (s *TokenSourceTestSuite) Test_CacheEmpty_NewToken_OKCallback_PKCE() {
+	s.oidcSource.cfg.PKCE = true
+	defer func() {
+		s.oidcSource.cfg.PKCE = false
+	}()
+	s.cache.On("Token").Return(nil, nil)
+	s.cache.On("SaveToken", &testToken).Return(nil)
+
+	const expectedWord = "secret_token"
+	s.oidcSource.genRandToken = func() string {
+		return expectedWord
+	}
+	// Example from RFC 7636 Appendix B.
+	s.oidcSource.genCodeVerifier = func() string {
+		return "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	}
+
+	callback := s.callSuccessfulCallback(expectedWord, testToken, "")
+	t := s.T()
+	// Token request (mocked by callSuccessfulCallback) needs to carry the code verifier.
+	s.provider.ExpectedRequests[len(s.provider.ExpectedRequests)-1].Check = func(r *http.Request) {
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk", r.PostForm.Get("code_verifier"))
+	}
+	s.oidcSource.openBrowser = func(urlToGet string) error {
+		u, err := url.Parse(urlToGet)
+		require.NoError(t, err)
+
+		q := u.Query()
+		require.Equal(t, "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM", q.Get("code_challenge"))
+		require.Equal(t, oidc.CodeChallengeMethodS256, q.Get("code_challenge_method"))
+
+		q.Del("code_challenge")
+		q.Del("code_challenge_method")
+		u.RawQuery = q.Encode()
+		return callback(u.String())
+	}
+	token, err := s.oidcSource.OIDCToken(context.Background())
+	s.Require().NoError(err)
+
+	s.Equal(testToken, *token)
+
+	s.cache.AssertExpectations(s.T())
+	s.Len(s.provider.ExpectedRequests, 0)
+}
+
+func (s *TokenSourceTestSuite) Test_CacheEmpty_NewToken_OKCallback_PAR() {
+	s.provider.MockDiscoveryCallWith(func(d *oidc.DiscoveryJSON) {
+		d.PushedAuthorizationRequestURL = s.provider.IssuerTestSrv.URL + "/par1"
+	})
+	parClient, err := oidc.NewClient(context.Background(), s.testOIDCCfg.Provider)
+	s.Require().NoError(err)
+
+	oldClient := s.oidcSource.oidcClient
+	s.oidcSource.oidcClient = parClient
+	defer func() {
+		s.oidcSource.oidcClient = oldClient
+	}()
+
+	s.cache.On("Token").Return(nil, nil)
+	s.cache.On("SaveToken", &testToken).Return(nil)
+
+	const expectedWord = "secret_token"
+	s.oidcSource.genRandToken = func() string {
+		return expectedWord
+	}
+
+	s.provider.MockPARCall(http.StatusCreated, `{"request_uri": "urn:ietf:params:oauth:request_uri:1", "expires_in": 60}`)
+	b, err := json.Marshal(testToken)
+	s.Require().NoError(err)
+	s.provider.MockTokenCall(http.StatusOK, string(b))
+
+	t := s.T()
+	s.oidcSource.openBrowser = func(urlToGet string) error {
+		s.Equal(fmt.Sprintf(
+			"%s/auth1?client_id=%s&request_uri=%s",
+			s.provider.IssuerTestSrv.URL,
+			testClientID,
+			url.QueryEscape("urn:ietf:params:oauth:request_uri:1"),
+		), urlToGet)
+
+		go func() {
+			res, err := http.Get(fmt.Sprintf("%s?code=%s&state=%s", s.oidcSource.callbackSrv.RedirectURL(), "code1", expectedWord))
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, res.StatusCode)
+		}()
+		return nil
+	}
+	token, err := s.oidcSource.OIDCToken(context.Background())
+	s.Require().NoError(err)
+
+	s.Equal(testToken, *token)
+
+	s.cache.AssertExpectations(s.T())
+	s.Len(s.provider.ExpectedRequests, 0)
+}
+
+func (s *TokenSourceTestSuite) Test_CacheEmpty_NewToken_OKCallback_JARM() {
+	s.oidcSource.cfg.ResponseMode = oidc.ResponseModeJWT
+	defer func() {
+		s.oidcSource.cfg.ResponseMode = ""
+	}()
+	s.cache.On("Token").Return(nil, nil)
+	s.cache.On("SaveToken", &testToken).Return(nil)
+
+	const expectedWord = "secret_token"
+	s.oidcSource.genRandToken = func() string {
+		return expectedWord
+	}
+
+	response, jwkSetJSON := s.provider.NewIDToken(testClientID, testSubject, "", map[string]interface{}{
+		"code":  "code1",
+		"state": expectedWord,
+	})
+	s.provider.MockPubKeysCall(jwkSetJSON)
+	b, err := json.Marshal(testToken)
+	s.Require().NoError(err)
+	s.provider.MockTokenCall(http.StatusOK, string(b))
+
+	t := s.T()
+	s.oidcSource.openBrowser = func(urlToGet string) error {
+		u, err := url.Parse(urlToGet)
+		require.NoError(t, err)
+		require.Equal(t, oidc.ResponseModeJWT, u.Query().Get("response_mode"))
+
+		go func() {
+			// Injected plain parameters are ignored.
+			res, err := http.PostForm(s.oidcSource.callbackSrv.RedirectURL(), url.Values{
+				"response": {response},
+				"code":     {"injected"},
+				"state":    {"injected"},
+			})
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, res.StatusCode)
+		}()
+		return nil
+	}
+	token, err := s.oidcSource.OIDCToken(context.Background())
+	s.Require().NoError(err)
+
+	s.Equal(testToken, *token)
+
+	s.cache.AssertExpectations(s.T())
+	s.Len(s.provider.ExpectedRequests, 0)
+}
+
+func (s *TokenSourceTestSuite) Test_CacheEmpty_NewToken_JARMMissingResponse_Err() {
+	s.oidcSource.cfg.ResponseMode = oidc.ResponseModeQueryJWT
+	defer func() {
+		s.oidcSource.cfg.ResponseMode = ""
+	}()
+	s.cache.On("Token").Return(nil, nil)
+
+	const expectedWord = "secret_token"
+	s.oidcSource.genRandToken = func() string {
+		return expectedWord
+	}
+
+	t := s.T()
+	s.oidcSource.openBrowser = func(string) error {
+		go func() {
+			res, err := http.Get(fmt.Sprintf("%s?code=%s&state=%s", s.oidcSource.callbackSrv.RedirectURL(), "code1", expectedWord))
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, res.StatusCode)
+		}()
+		return nil
+	}
+	_, err := s.oidcSource.OIDCToken(context.Background())
+	s.Require().Error(err)
+	s.Equal("Failed to obtain new token. Err: oidc: Callback error: Failed to verify authorization response. Err: oidc: missing authorization response JWT", err.Error())
+
+	s.cache.AssertExpectations(s.T())
+	s.Len(s.provider.ExpectedRequests, 0)
+}
+
+func (s *TokenSourceTestSuite) Test_CacheEmpty_NewToken_OKCallback_Hybrid() {
+	s.oidcSource.cfg.ResponseType = oidc.ResponseTypeCodeIDToken
+	defer func() {
+		s.oidcSource.cfg.ResponseType = ""
+	}()
+
+	const expectedWord = "secret_token"
+	s.oidcSource.genRandToken = func() string {
+		return expectedWord
+	}
+
+	cHash, err := oidc.TokenHash("RS256", "code1")
+	s.Require().NoError(err)
+	frontChannelIDToken, jwkSetJSON := s.provider.NewIDToken(testClientID, testSubject, expectedWord, map[string]interface{}{
+		"c_hash": cHash,
+	})
+	idToken, jwkSetJSON2 := s.provider.NewIDToken(testClientID, testSubject, expectedWord)
+	expectedToken := testToken
+	expectedToken.IDToken = idToken
+
+	s.cache.On("Token").Return(nil, nil)
+	s.cache.On("SaveToken", &expectedToken).Return(nil)
+
+	// Front-channel ID token is verified before the code is exchanged.
+	s.provider.MockPubKeysCall(jwkSetJSON)
+	b, err := json.Marshal(expectedToken)
+	s.Require().NoError(err)
+	s.provider.MockTokenCall(http.StatusOK, string(b))
+	s.provider.MockPubKeysCall(jwkSetJSON2)
+
+	t := s.T()
+	s.oidcSource.openBrowser = func(urlToGet string) error {
+		u, err := url.Parse(urlToGet)
+		require.NoError(t, err)
+		require.Equal(t, oidc.ResponseTypeCodeIDToken, u.Query().Get("response_type"))
+		require.Equal(t, expectedWord, u.Query().Get("nonce"))
+
+		go func() {
+			// Fragment is not sent to the server, so callback page posting it back is served.
+			res, err := http.Get(s.oidcSource.callbackSrv.RedirectURL())
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, res.StatusCode)
+			body, err := ioutil.ReadAll(res.Body)
+			require.NoError(t, err)
+			require.Equal(t, DefaultFragmentCallbackHTML, string(body))
+
+			res, err = http.PostForm(s.oidcSource.callbackSrv.RedirectURL(), url.Values{
+				"code":     {"code1"},
+				"state":    {expectedWord},
+				"id_token": {frontChannelIDToken},
+			})
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, res.StatusCode)
+		}()
+		return nil
+	}
+	token, err := s.oidcSource.OIDCToken(context.Background())
+	s.Require().NoError(err)
+
+	s.Equal(expectedToken, *token)
+
+	s.cache.AssertExpectations(s.T())
+	s.Len(s.provider.ExpectedRequests, 0)
+}
+
+func (s *TokenSourceTestSuite) Test_CacheEmpty_NewToken_HybridWrongCodeHash_Err() {
+	s.oidcSource.cfg.ResponseType = oidc.ResponseTypeCodeIDToken
+	s.oidcSource.cfg.ResponseMode = oidc.ResponseModeFormPost
+	defer func() {
+		s.oidcSource.cfg.ResponseType = ""
+		s.oidcSource.cfg.ResponseMode = ""
+	}()
+
+	const expectedWord = "secret_token"
+	s.oidcSource.genRandToken = func() string {
+		return expectedWord
+	}
+
+	cHash, err := oidc.TokenHash("RS256", "code1")
+	s.Require().NoError(err)
+	frontChannelIDToken, jwkSetJSON := s.provider.NewIDToken(testClientID, testSubject, expectedWord, map[string]interface{}{
+		"c_hash": cHash,
+	})
+	s.cache.On("Token").Return(nil, nil)
+	s.provider.MockPubKeysCall(jwkSetJSON)
+
+	t := s.T()
+	s.oidcSource.openBrowser = func(string) error {
+		go func() {
+			// Injected code is not exchanged.
+			res, err := http.PostForm(s.oidcSource.callbackSrv.RedirectURL(), url.Values{
+				"code":     {"injected"},
+				"state":    {expectedWord},
+				"id_token": {frontChannelIDToken},
+			})
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, res.StatusCode)
+		}()
+		return nil
+	}
+	_, err = s.oidcSource.OIDCToken(context.Background())
+	s.Require().Error(err)
+	s.Equal("Failed to obtain new token. Err: oidc: Callback error: Failed to verify ID token from auth endpoint. Err: oidc: ID token c_hash claim does not match", err.Error())
+
+	s.cache.AssertExpectations(s.T())
+	s.Len(s.provider.ExpectedRequests, 0)
+}
+
+func (s *TokenSourceTestSuite) Test_CacheEmpty_NewToken_HybridWrongAccessTokenHash_Err() {
+	s.oidcSource.cfg.ResponseType = oidc.ResponseTypeCodeIDTokenToken
+	s.oidcSource.cfg.ResponseMode = oidc.ResponseModeFormPost
+	defer func() {
+		s.oidcSource.cfg.ResponseType = ""
+		s.oidcSource.cfg.ResponseMode = ""
+	}()
+
+	const expectedWord = "secret_token"
+	s.oidcSource.genRandToken = func() string {
+		return expectedWord
+	}
+
+	cHash, err := oidc.TokenHash("RS256", "code1")
+	s.Require().NoError(err)
+	atHash, err := oidc.TokenHash("RS256", "access1")
+	s.Require().NoError(err)
+	frontChannelIDToken, jwkSetJSON := s.provider.NewIDToken(testClientID, testSubject, expectedWord, map[string]interface{}{
+		"c_hash":  cHash,
+		"at_hash": atHash,
+	})
+	s.cache.On("Token").Return(nil, nil)
+	s.provider.MockPubKeysCall(jwkSetJSON)
+
+	t := s.T()
+	s.oidcSource.openBrowser = func(string) error {
+		go func() {
+			// Injected access token is rejected and code is not exchanged.
+			res, err := http.PostForm(s.oidcSource.callbackSrv.RedirectURL(), url.Values{
+				"code":         {"code1"},
+				"state":        {expectedWord},
+				"id_token":     {frontChannelIDToken},
+				"access_token": {"injected"},
+				"token_type":   {"Bearer"},
+			})
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, res.StatusCode)
+		}()
+		return nil
+	}
+	_, err = s.oidcSource.OIDCToken(context.Background())
+	s.Require().Error(err)
+	s.Equal("Failed to obtain new token. Err: oidc: Callback error: Failed to verify access token from auth endpoint. Err: oidc: ID token at_hash claim does not match", err.Error())
+
+	s.cache.AssertExpectations(s.T())
+	s.Len(s.provider.ExpectedRequests, 0)
+}
+
+func (s *TokenSourceTestSuite) Test_CacheEmpty_NewToken_OKCallback_ImplicitFormPost() {
+	s.oidcSource.cfg.ResponseType = oidc.ResponseTypeIDTokenToken
+	s.oidcSource.cfg.ResponseMode = oidc.ResponseModeFormPost
+	defer func() {
+		s.oidcSource.cfg.ResponseType = ""
+		s.oidcSource.cfg.ResponseMode = ""
+	}()
+
+	const expectedWord = "secret_token"
+	s.oidcSource.genRandToken = func() string {
+		return expectedWord
+	}
+
+	atHash, err := oidc.TokenHash("RS256", "access1")
+	s.Require().NoError(err)
+	idToken, jwkSetJSON := s.provider.NewIDToken(testClientID, testSubject, expectedWord, map[string]interface{}{
+		"at_hash": atHash,
+	})
+	s.cache.On("Token").Return(nil, nil)
+	s.cache.On("SaveToken", mock.Anything).Return(nil)
+	s.provider.MockPubKeysCall(jwkSetJSON)
+
+	t := s.T()
+	s.oidcSource.openBrowser = func(urlToGet string) error {
+		u, err := url.Parse(urlToGet)
+		require.NoError(t, err)
+		require.Equal(t, oidc.ResponseModeFormPost, u.Query().Get("response_mode"))
+
+		go func() {
+			res, err := http.PostForm(s.oidcSource.callbackSrv.RedirectURL(), url.Values{
+				"state":        {expectedWord},
+				"id_token":     {idToken},
+				"access_token": {"access1"},
+				"token_type":   {"Bearer"},
+				"expires_in":   {"3600"},
+			})
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, res.StatusCode)
+		}()
+		return nil
+	}
+	token, err := s.oidcSource.OIDCToken(context.Background())
+	s.Require().NoError(err)
+
+	s.Equal("access1", token.AccessToken)
+	s.Equal(idToken, token.IDToken)
+	s.Empty(token.RefreshToken)
+	s.False(token.IsAccessTokenExpired())
+	s.False(token.AccessTokenExpiry.IsZero())
+
+	s.cache.AssertExpectations(s.T())
+	s.Len(s.provider.ExpectedRequests, 0)
+}
+
 func (s *TokenSourceTestSuite) Test_IDTokenWrongNonce_RefreshToken_OK() {
 	idToken, jwkSetJSON := s.provider.NewIDToken(testClientID, testSubject, "wrongNonce")
 	invalidToken := testToken
@@ -307,6 +722,42 @@ func (s *TokenSourceTestSuite) Test_IDTokenWrongNonce_RefreshToken_OK() {
 	s.Len(s.provider.ExpectedRequests, 0)
 }
 
+func (s *TokenSourceTestSuite) Test_AccessTokenNotBoundToIDToken_RefreshToken_OK() {
+	atHash, err := oidc.TokenHash("RS256", "other_access")
+	s.Require().NoError(err)
+	idToken, jwkSetJSON := s.provider.NewIDToken(testClientID, testSubject, s.oidcSource.nonce, map[string]interface{}{
+		"at_hash": atHash,
+	})
+	invalidToken := testToken
+	invalidToken.IDToken = idToken
+	s.cache.On("Token").Return(&invalidToken, nil)
+
+	idTokenOK, jwkSetJSON2 := s.provider.NewIDToken(testClientID, testSubject, s.oidcSource.nonce)
+	expectedToken := testToken
+	expectedToken.IDToken = idTokenOK
+	s.cache.On("SaveToken", &expectedToken).Return(nil)
+
+	// Cached access token does not match at_hash, so token is refreshed.
+	s.provider.MockPubKeysCall(jwkSetJSON)
+	b, err := json.Marshal(oidc.TokenResponse{
+		AccessToken:  expectedToken.AccessToken,
+		RefreshToken: expectedToken.RefreshToken,
+		IDToken:      expectedToken.IDToken,
+		TokenType:    "Bearer",
+	})
+	s.Require().NoError(err)
+	s.provider.MockTokenCall(http.StatusOK, string(b))
+	s.provider.MockPubKeysCall(jwkSetJSON2)
+
+	token, err := s.oidcSource.OIDCToken(context.Background())
+	s.Require().NoError(err)
+
+	s.Equal(expectedToken, *token)
+
+	s.cache.AssertExpectations(s.T())
+	s.Len(s.provider.ExpectedRequests, 0)
+}
+
 func (s *TokenSourceTestSuite) Test_IDTokenWrongNonce_RefreshTokenErr_NewToken_OK() {
 	idToken, jwkSetJSON := s.provider.NewIDToken(testClientID, testSubject, "wrongNonce")
 	invalidToken := testToken
@@ -316,7 +767,7 @@ func (s *TokenSourceTestSuite) Test_IDTokenWrongNonce_RefreshTokenErr_NewToken_O
 
 	// For first verification inside OIDC TokenSource.
 	s.provider.MockPubKeysCall(jwkSetJSON)
-	s.provider.MockTokenCall(http.StatusBadRequest, `{"error": "bad_request"}`)
+	s.provider.MockTokenCall(http.StatusBadRequest, `{"error": "invalid_grant"}`)
 
 	const expectedWord = "secret_token"
 	s.oidcSource.genRandToken = func() string {
@@ -333,6 +784,63 @@ func (s *TokenSourceTestSuite) Test_IDTokenWrongNonce_RefreshTokenErr_NewToken_O
 	s.Len(s.provider.ExpectedRequests, 0)
 }
 
+func (s *TokenSourceTestSuite) Test_IDTokenWrongNonce_RefreshTokenUnavailable_Err() {
+	idToken, jwkSetJSON := s.provider.NewIDToken(testClientID, testSubject, "wrongNonce")
+	invalidToken := testToken
+	invalidToken.IDToken = idToken
+	s.cache.On("Token").Return(&invalidToken, nil)
+
+	// For first verification inside OIDC TokenSource.
+	s.provider.MockPubKeysCall(jwkSetJSON)
+	s.provider.MockTokenCall(http.StatusServiceUnavailable, "")
+
+	s.oidcSource.openBrowser = func(string) error {
+		s.Fail("login should not be triggered when provider is unavailable")
+		return nil
+	}
+
+	_, err := s.oidcSource.OIDCToken(context.Background())
+	s.Require().Error(err)
+	s.Equal("Failed to refresh token. Err: oauth2: cannot fetch token: 503 Service Unavailable\nResponse: \n", err.Error())
+
+	s.cache.AssertExpectations(s.T())
+	s.Len(s.provider.ExpectedRequests, 0)
+}
+
+func (s *TokenSourceTestSuite) Test_IDTokenWrongNonce_RefreshToken_KeysUnavailable_Err() {
+	idToken, jwkSetJSON := s.provider.NewIDToken(testClientID, testSubject, "wrongNonce")
+	invalidToken := testToken
+	invalidToken.IDToken = idToken
+	s.cache.On("Token").Return(&invalidToken, nil)
+
+	idTokenOkNonce, _ := s.provider.NewIDToken(testClientID, testSubject, s.oidcSource.nonce)
+	b, err := json.Marshal(oidc.TokenResponse{
+		AccessToken:  testToken.AccessToken,
+		RefreshToken: testToken.RefreshToken,
+		IDToken:      idTokenOkNonce,
+		TokenType:    "Bearer",
+	})
+	s.Require().NoError(err)
+
+	// For first verification inside OIDC TokenSource.
+	s.provider.MockPubKeysCall(jwkSetJSON)
+	s.provider.MockTokenCall(http.StatusOK, string(b))
+	// Refreshed ID token cannot be verified, since provider's keys are not available.
+	s.provider.MockPubKeysCallErr(http.StatusServiceUnavailable)
+
+	s.oidcSource.openBrowser = func(string) error {
+		s.Fail("login should not be triggered when provider's keys are unavailable")
+		return nil
+	}
+
+	_, err = s.oidcSource.OIDCToken(context.Background())
+	s.Require().Error(err)
+	s.Contains(err.Error(), "Failed to refresh token. Err: failed to verify idToken from provider. Err: oidc: get keys for id token")
+
+	s.cache.AssertExpectations(s.T())
+	s.Len(s.provider.ExpectedRequests, 0)
+}
+
 func (s *TokenSourceTestSuite) Test_CacheEmpty_NewToken_ErrCallback() {
 	s.cache.On("Token").Return(nil, nil)
 	s.provider.MockTokenCall(http.StatusServiceUnavailable, "")
@@ -418,3 +926,89 @@ func (s *TokenSourceTestSuite) Test_ClearIDToken_ClearOnlyIDToken() {
 	s.Require().NoError(s.oidcSource.clearIDToken(func() {})())
 	s.cache.AssertExpectations(s.T())
 }
+
+func (s *TokenSourceTestSuite) Test_Logout_RevokeEndSessionAndWipeCache() {
+	token := oidc.Token{
+		AccessToken:  "accessToken",
+		IDToken:      "idToken",
+		RefreshToken: "refreshToken",
+	}
+	s.cache.On("Token").Return(&token, nil)
+	s.cache.On("SaveToken", &oidc.Token{}).Return(nil)
+
+	s.provider.MockRevokeCall(http.StatusOK)
+	s.provider.MockRevokeCall(http.StatusOK)
+
+	const expectedState = "logout_state"
+	s.oidcSource.genRandToken = func() string {
+		return expectedState
+	}
+
+	// testify/suite is not thread safe, go test -race fails.
+	t := s.T()
+	s.oidcSource.openBrowser = func(urlToGet string) error {
+		redirectURL, err := stripArgFromURL("post_logout_redirect_uri", urlToGet)
+		require.NoError(t, err)
+
+		s.Equal(fmt.Sprintf(
+			"%s/logout1?client_id=%s&id_token_hint=%s&post_logout_redirect_uri=%s&state=%s",
+			s.provider.IssuerTestSrv.URL,
+			testClientID,
+			token.IDToken,
+			url.QueryEscape(redirectURL),
+			expectedState,
+		), urlToGet)
+
+		go func() {
+			res, err := http.Get(fmt.Sprintf("%s?state=%s", redirectURL, expectedState))
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, res.StatusCode)
+		}()
+		return nil
+	}
+
+	s.Require().NoError(s.oidcSource.Logout(context.Background()))
+
+	s.cache.AssertExpectations(s.T())
+	s.Len(s.provider.ExpectedRequests, 0)
+}
+
+func (s *TokenSourceTestSuite) Test_Logout_RevokeErr_CacheWiped() {
+	token := oidc.Token{
+		AccessToken:  "accessToken",
+		RefreshToken: "refreshToken",
+	}
+	s.cache.On("Token").Return(&token, nil)
+	s.cache.On("SaveToken", &oidc.Token{}).Return(nil)
+
+	// Both tokens are revoked, even though revoking refresh token fails.
+	s.provider.MockRevokeCall(http.StatusServiceUnavailable)
+	s.provider.MockRevokeCall(http.StatusServiceUnavailable)
+
+	const expectedState = "logout_state"
+	s.oidcSource.genRandToken = func() string {
+		return expectedState
+	}
+	t := s.T()
+	s.oidcSource.openBrowser = func(urlToGet string) error {
+		redirectURL, err := stripArgFromURL("post_logout_redirect_uri", urlToGet)
+		require.NoError(t, err)
+
+		go func() {
+			res, err := http.Get(fmt.Sprintf("%s?state=%s", redirectURL, "wrong_state"))
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, res.StatusCode)
+		}()
+		return nil
+	}
+
+	err := s.oidcSource.Logout(context.Background())
+	s.Require().Error(err)
+	s.Contains(err.Error(), "Logout failed: ")
+	s.Contains(err.Error(), "failed to revoke refresh_token. Err: oidc: cannot revoke token: 503 Service Unavailable")
+	s.Contains(err.Error(), "failed to revoke access_token. Err: oidc: cannot revoke token: 503 Service Unavailable")
+	s.Contains(err.Error(), "oidc: Logout callback error: Invalid state parameter. Got wrong_state, expected: logout_state")
+
+	s.cache.AssertExpectations(s.T())
+	s.Len(s.provider.ExpectedRequests, 0)
+}
@@ -0,0 +1,65 @@
+package oidc
+
+import (
+	"errors"
+	"net/url"
+)
+
+// Token type hints for revocation and introspection requests (RFC 7009 section 2.1).
+const (
+	TokenTypeHintAccessToken  = "access_token"
+	TokenTypeHintRefreshToken = "refresh_token"
+)
+
+// EndSessionRequest is RP-Initiated Logout request as described in
+// https://openid.net/specs/openid-connect-rpinitiated-1_0.html#RPLogout.
+type EndSessionRequest struct {
+	// IDTokenHint is previously issued ID token of the user. It can be expired. Recommended.
+	IDTokenHint string
+	// LogoutHint is a hint about the user that is logging out e.g email. Optional.
+	LogoutHint string
+
+	// PostLogoutRedirectURL is where provider redirects user agent after logout. It needs to be registered with the
+	// provider. Optional.
+	PostLogoutRedirectURL string
+	// State is passed back to PostLogoutRedirectURL, so RP can maintain state between logout request and the callback.
+	State string
+}
+
+// EndSessionURL returns a URL to OIDC provider's end session endpoint that logs the user out from the provider.
+// Client ID from cfg is always passed, so provider can verify PostLogoutRedirectURL even without IDTokenHint.
+func (c *Client) EndSessionURL(cfg Config, r EndSessionRequest, extra ...url.Values) (string, error) {
+	endSessionURL := c.Discovery().EndSessionURL
+	if endSessionURL == "" {
+		return "", errors.New("oidc: end session endpoint is not supported by this provider")
+	}
+
+	v := url.Values{}
+	if cfg.ClientID != "" {
+		v.Set("client_id", cfg.ClientID)
+	}
+	if r.IDTokenHint != "" {
+		v.Set("id_token_hint", r.IDTokenHint)
+	}
+	if r.LogoutHint != "" {
+		v.Set("logout_hint", r.LogoutHint)
+	}
+	if r.PostLogoutRedirectURL != "" {
+		v.Set("post_logout_redirect_uri", r.PostLogoutRedirectURL)
+	}
+	if r.State != "" {
+		v.Set("state", r.State)
+	}
+
+	for _, e := range extra {
+		for key := range e {
+			v.Set(key, e.Get(key))
+		}
+	}
+
+	if len(v) == 0 {
+		return endSessionURL, nil
+	}
+
+	return appendQuery(endSessionURL, v), nil
+}
@@ -0,0 +1,49 @@
+package oidc
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/bwplotka/go-httpt/rt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *ClientTestSuite) TestEndSessionURL() {
+	_, err := s.client.EndSessionURL(Config{ClientID: "client1"}, EndSessionRequest{})
+	s.Require().Error(err)
+
+	client := s.clientWithDiscovery(func(d *DiscoveryJSON) {
+		d.EndSessionURL = exampleIssuer + "/logout1?realm=1"
+	})
+	endSessionURL, err := client.EndSessionURL(Config{ClientID: "client1"}, EndSessionRequest{
+		IDTokenHint:           "idtoken1",
+		LogoutHint:            "user@example.com",
+		PostLogoutRedirectURL: "http://127.0.0.1/callback",
+		State:                 "state1",
+	}, url.Values{"ui_locales": {"en"}})
+	s.Require().NoError(err)
+	s.Equal(exampleIssuer+"/logout1?realm=1&client_id=client1&id_token_hint=idtoken1&logout_hint=user%40example.com&"+
+		"post_logout_redirect_uri=http%3A%2F%2F127.0.0.1%2Fcallback&state=state1&ui_locales=en", endSessionURL)
+}
+
+func (s *ClientTestSuite) TestRevoke_TokenTypeHint() {
+	s.s.On("POST", testDiscovery.RevocationURL).Push(func(r *http.Request) (*http.Response, error) {
+		s.Require().NoError(r.ParseForm())
+		s.Equal("refresh1", r.PostForm.Get("token"))
+		s.Equal(TokenTypeHintRefreshToken, r.PostForm.Get("token_type_hint"))
+		return rt.StringResponseFunc(http.StatusOK, "")(r)
+	})
+
+	err := s.client.Revoke(s.testCtx, Config{ClientID: "client1", ClientSecret: "secret1"}, "refresh1", url.Values{"token_type_hint": {TokenTypeHintRefreshToken}})
+	s.Require().NoError(err)
+	s.Equal(0, s.s.Len())
+}
+
+func TestEndSessionURL_NoParams(t *testing.T) {
+	c := &Client{discovery: DiscoveryJSON{EndSessionURL: exampleIssuer + "/logout1"}}
+	endSessionURL, err := c.EndSessionURL(Config{}, EndSessionRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, exampleIssuer+"/logout1", endSessionURL)
+}
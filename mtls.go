@@ -0,0 +1,96 @@
+package oidc
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+)
+
+// ClientAuthMethodTLS and ClientAuthMethodSelfSignedTLS are mutual TLS client authentication methods (RFC 8705).
+const (
+	ClientAuthMethodTLS           = "tls_client_auth"
+	ClientAuthMethodSelfSignedTLS = "self_signed_tls_client_auth"
+)
+
+// MTLSEndpointAliases are alternative endpoints that provider exposes for mutual TLS (RFC 8705 section 5).
+// Empty alias means regular endpoint should be used.
+type MTLSEndpointAliases struct {
+	TokenURL               string `json:"token_endpoint,omitempty"`
+	UserInfoURL            string `json:"userinfo_endpoint,omitempty"`
+	RevocationURL          string `json:"revocation_endpoint,omitempty"`
+	DeviceAuthorizationURL string `json:"device_authorization_endpoint,omitempty"`
+	IntrospectionURL       string `json:"introspection_endpoint,omitempty"`
+	// PushedAuthorizationRequestURL is an alias for PAR endpoint (RFC 9126 section 5).
+	PushedAuthorizationRequestURL string `json:"pushed_authorization_request_endpoint,omitempty"`
+}
+
+// mtlsEndpoint returns mTLS alias for given endpoint if the provider advertises one.
+func (d DiscoveryJSON) mtlsEndpoint(endpoint string) string {
+	if d.MTLSEndpointAliases == nil {
+		return endpoint
+	}
+
+	a := d.MTLSEndpointAliases
+	for _, pair := range [][2]string{
+		{d.TokenURL, a.TokenURL},
+		{d.UserInfoURL, a.UserInfoURL},
+		{d.RevocationURL, a.RevocationURL},
+		{d.DeviceAuthorizationURL, a.DeviceAuthorizationURL},
+		{d.IntrospectionURL, a.IntrospectionURL},
+		{d.PushedAuthorizationRequestURL, a.PushedAuthorizationRequestURL},
+	} {
+		if pair[0] == endpoint && pair[1] != "" {
+			return pair[1]
+		}
+	}
+	return endpoint
+}
+
+// TLSClientAuth authenticates the client using mutual TLS with client certificate (RFC 8705). Requests are sent to
+// mtls_endpoint_aliases if provider advertises them. Tokens issued this way are usually bound to the certificate
+// (cnf.x5t#S256 claim).
+//
+// HTTP client with the certificate is derived from Client's HTTP client once per TLSClientAuth, so it must not be
+// modified after first use.
+// NOTE: If HTTP client is passed using HTTPClientCtxKey, it is used as is and needs to be configured with the certificate.
+type TLSClientAuth struct {
+	// Certificate is a client certificate with private key presented in TLS handshake.
+	Certificate tls.Certificate
+	// RootCAs is an optional set of root CAs used to verify provider's certificate. System pool is used if nil.
+	RootCAs *x509.CertPool
+	// SelfSigned indicates that certificate is self-signed and registered with the provider directly
+	// (self_signed_tls_client_auth), instead of being issued by trusted CA (tls_client_auth).
+	SelfSigned bool
+}
+
+// Method returns "tls_client_auth" or "self_signed_tls_client_auth".
+func (a *TLSClientAuth) Method() string {
+	if a.SelfSigned {
+		return ClientAuthMethodSelfSignedTLS
+	}
+	return ClientAuthMethodTLS
+}
+
+// AuthenticateRequest sets client_id form value. Client itself is authenticated on TLS layer.
+func (a *TLSClientAuth) AuthenticateRequest(cfg Config, _ string, v url.Values, _ http.Header) error {
+	v.Set("client_id", cfg.ClientID)
+	return nil
+}
+
+// Confirmation is a "cnf" claim that binds the token to a key (RFC 7800).
+type Confirmation struct {
+	// X5tS256 is a base64url encoded SHA-256 thumbprint of the client certificate the token is bound to (RFC 8705).
+	X5tS256 string `json:"x5t#S256,omitempty"`
+	// JKT is a base64url encoded RFC 7638 thumbprint of the DPoP key the token is bound to (RFC 9449).
+	JKT string `json:"jkt,omitempty"`
+}
+
+// CertificateThumbprint returns base64url encoded SHA-256 thumbprint of DER encoded certificate as used in
+// cnf.x5t#S256 claim.
+func CertificateThumbprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,138 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bwplotka/go-httpt/rt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *ClientTestSuite) TestTLSClientAuth_UsesMTLSAliases() {
+	mtlsTokenURL := exampleIssuer + "/mtls/token1"
+
+	client := s.clientWithDiscovery(func(d *DiscoveryJSON) {
+		d.MTLSEndpointAliases = &MTLSEndpointAliases{
+			TokenURL: mtlsTokenURL,
+		}
+	})
+
+	s.s.On("POST", mtlsTokenURL).Push(func(req *http.Request) (*http.Response, error) {
+		s.Require().NoError(req.ParseForm())
+		_, _, ok := req.BasicAuth()
+		s.False(ok)
+		s.Equal("client1", req.PostForm.Get("client_id"))
+		s.Empty(req.PostForm.Get("client_secret"))
+		s.Empty(req.PostForm.Get("client_assertion"))
+		return rt.JSONResponseFunc(http.StatusOK, []byte(`{"access_token": "access1", "token_type": "Bearer", "expires_in": 3600}`))(req)
+	})
+	// No alias for revocation endpoint, so regular one is used.
+	s.s.On("POST", testDiscovery.RevocationURL).Push(rt.StringResponseFunc(http.StatusOK, ""))
+
+	cfg := Config{
+		ClientID:   "client1",
+		ClientAuth: &TLSClientAuth{},
+	}
+	token, err := NewClientCredentialsTokenSource(client, cfg, "").OIDCToken(s.testCtx)
+	s.Require().NoError(err)
+	s.Equal("access1", token.AccessToken)
+
+	s.Require().NoError(client.Revoke(s.testCtx, cfg, token.AccessToken))
+
+	s.Equal(0, s.s.Len())
+}
+
+func (s *ClientTestSuite) TestCertificateThumbprint() {
+	// SHA-256 of "abc".
+	s.Equal("ungWv48Bz-pBQUDeXa4iI7ADYaOWF3qctBD_YfIAFa0", CertificateThumbprint(&x509.Certificate{Raw: []byte("abc")}))
+}
+
+func TestTLSClientAuth_PresentsCertificate(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "client1"},
+		NotBefore:    time.Now().Add(-1 * time.Minute),
+		NotAfter:     time.Now().Add(1 * time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "client1"}}, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	// mTLS alias lives on separate server that requires client certificate in TLS handshake.
+	peerCerts := make(chan []*x509.Certificate, 1)
+	mtlsSrv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/mtls/token1", r.URL.Path)
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, "client1", r.PostForm.Get("client_id"))
+		peerCerts <- r.TLS.PeerCertificates
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token": "access1", "token_type": "Bearer", "expires_in": 3600}`)
+	}))
+	mtlsSrv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	mtlsSrv.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	mtlsSrv.StartTLS()
+	defer mtlsSrv.Close()
+
+	var srv *httptest.Server
+	srv = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != DiscoveryEndpoint {
+			t.Errorf("unexpected request to %s, only discovery is expected on regular endpoints", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		assert.NoError(t, json.NewEncoder(w).Encode(DiscoveryJSON{
+			Issuer:   srv.URL,
+			TokenURL: srv.URL + "/token1",
+			JWKSURL:  srv.URL + "/jwks1",
+			MTLSEndpointAliases: &MTLSEndpointAliases{
+				TokenURL: mtlsSrv.URL + "/mtls/token1",
+			},
+		}))
+	}))
+	defer srv.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+	pool.AddCert(mtlsSrv.Certificate())
+
+	client, err := NewClient(context.Background(), srv.URL, WithRootCAs(pool))
+	require.NoError(t, err)
+
+	cfg := Config{
+		ClientID: "client1",
+		ClientAuth: &TLSClientAuth{
+			Certificate: tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key},
+			SelfSigned:  true,
+		},
+	}
+	token, err := NewClientCredentialsTokenSource(client, cfg, "").OIDCToken(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "access1", token.AccessToken)
+
+	certs := <-peerCerts
+	require.Len(t, certs, 1)
+	assert.Equal(t, der, certs[0].Raw)
+
+	// Without client certificate the mTLS endpoint is not reachable.
+	noCertClient := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	_, err = noCertClient.Post(mtlsSrv.URL+"/mtls/token1", "application/x-www-form-urlencoded", nil)
+	require.Error(t, err)
+}
@@ -0,0 +1,65 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+// PushedAuthorizationResponse is a response from pushed authorization request endpoint (RFC 9126 section 2.2).
+type PushedAuthorizationResponse struct {
+	// RequestURI references the pushed authorization request. Pass it to AuthCodeURLWithRequestURI.
+	RequestURI string `json:"request_uri"`
+	// ExpiresIn is lifetime of the RequestURI in seconds.
+	ExpiresIn int `json:"expires_in"`
+}
+
+// PushAuthorizationRequest posts authorization request with the same parameters as AuthCodeURL directly to provider's
+// pushed authorization request endpoint (PAR, RFC 9126). The client is authenticated the same way as on the token
+// endpoint. If cfg.RequestObjectSigner is set, parameters are pushed as signed request object (RFC 9126 section 3).
+// Use AuthCodeURLWithRequestURI with returned request URI to redirect the user, so none of the authorization
+// parameters (e.g login_hint) are exposed in the front channel.
+func (c *Client) PushAuthorizationRequest(ctx context.Context, cfg Config, state string, extra ...url.Values) (*PushedAuthorizationResponse, error) {
+	parURL := c.Discovery().PushedAuthorizationRequestURL
+	if parURL == "" {
+		return nil, errors.New("oidc: pushed authorization request endpoint is not supported by this provider")
+	}
+
+	v := authCodeParams(cfg, state, extra...)
+	if cfg.RequestObjectSigner != nil {
+		requestObject, err := c.RequestObject(cfg, state, extra...)
+		if err != nil {
+			return nil, err
+		}
+		v = url.Values{"client_id": {cfg.ClientID}, "request": {requestObject}}
+	}
+
+	r, body, err := c.postForm(ctx, cfg, parURL, v)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: cannot push authorization request: %v", err)
+	}
+	if code := r.StatusCode; code < 200 || code > 299 {
+		return nil, fmt.Errorf("oidc: cannot push authorization request: %v\nResponse: %s", r.Status, body)
+	}
+
+	var resp PushedAuthorizationResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("oidc: failed to decode pushed authorization response: %v", err)
+	}
+	if resp.RequestURI == "" {
+		return nil, fmt.Errorf("oidc: pushed authorization response is missing request_uri. Response: %s", body)
+	}
+	return &resp, nil
+}
+
+// AuthCodeURLWithRequestURI returns a short URL to OIDC provider's consent page that references authorization request
+// pushed with PushAuthorizationRequest or request object hosted by the client (see RequestObject).
+// Only client_id and request_uri are passed in the URL.
+func (c *Client) AuthCodeURLWithRequestURI(cfg Config, requestURI string) string {
+	return appendQuery(c.Discovery().AuthURL, url.Values{
+		"client_id":   {cfg.ClientID},
+		"request_uri": {requestURI},
+	})
+}
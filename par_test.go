@@ -0,0 +1,75 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/url"
+
+	"github.com/bwplotka/go-httpt/rt"
+)
+
+func (s *ClientTestSuite) TestPushAuthorizationRequest() {
+	cfg := Config{
+		ClientID:     "client1",
+		ClientSecret: "secret1",
+		RedirectURL:  "http://127.0.0.1/callback",
+		Scopes:       []string{ScopeOpenID},
+	}
+	_, err := s.client.PushAuthorizationRequest(s.testCtx, cfg, "state1")
+	s.Require().Error(err)
+
+	client := s.clientWithDiscovery(func(d *DiscoveryJSON) {
+		d.PushedAuthorizationRequestURL = exampleIssuer + "/par1"
+	})
+
+	s.s.On("POST", exampleIssuer+"/par1").Push(func(r *http.Request) (*http.Response, error) {
+		s.Require().NoError(r.ParseForm())
+		s.Equal(url.Values{
+			"response_type": {ResponseTypeCode},
+			"client_id":     {"client1"},
+			"redirect_uri":  {"http://127.0.0.1/callback"},
+			"state":         {"state1"},
+			"scope":         {ScopeOpenID},
+			"login_hint":    {"user@example.com"},
+		}, r.PostForm)
+		user, pass, ok := r.BasicAuth()
+		s.True(ok)
+		s.Equal("client1", user)
+		s.Equal("secret1", pass)
+
+		return rt.JSONResponseFunc(http.StatusCreated, []byte(
+			`{"request_uri": "urn:ietf:params:oauth:request_uri:abc", "expires_in": 60}`,
+		))(r)
+	})
+
+	resp, err := client.PushAuthorizationRequest(s.testCtx, cfg, "state1", url.Values{"login_hint": {"user@example.com"}})
+	s.Require().NoError(err)
+	s.Equal(PushedAuthorizationResponse{RequestURI: "urn:ietf:params:oauth:request_uri:abc", ExpiresIn: 60}, *resp)
+
+	s.Equal(
+		exampleIssuer+"/auth1?client_id=client1&request_uri=urn%3Aietf%3Aparams%3Aoauth%3Arequest_uri%3Aabc",
+		client.AuthCodeURLWithRequestURI(cfg, resp.RequestURI),
+	)
+
+	// Signed request object is pushed instead of plain parameters.
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	s.Require().NoError(err)
+	jarCfg := cfg
+	jarCfg.RequestObjectSigner = &RequestObjectSigner{Signer: key}
+	s.s.On("POST", exampleIssuer+"/par1").Push(func(r *http.Request) (*http.Response, error) {
+		s.Require().NoError(r.ParseForm())
+		s.Equal("client1", r.PostForm.Get("client_id"))
+		s.NotEmpty(r.PostForm.Get("request"))
+		s.Empty(r.PostForm.Get("redirect_uri"))
+		return rt.JSONResponseFunc(http.StatusCreated, []byte(`{"request_uri": "urn:ietf:params:oauth:request_uri:def", "expires_in": 60}`))(r)
+	})
+	_, err = client.PushAuthorizationRequest(s.testCtx, jarCfg, "state1")
+	s.Require().NoError(err)
+
+	s.s.On("POST", exampleIssuer+"/par1").Push(rt.JSONResponseFunc(http.StatusBadRequest, []byte(`{"error": "invalid_request"}`)))
+	_, err = client.PushAuthorizationRequest(s.testCtx, cfg, "state1")
+	s.Require().Error(err)
+
+	s.Equal(0, s.s.Len())
+}
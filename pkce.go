@@ -0,0 +1,88 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/url"
+)
+
+const (
+	// CodeChallengeMethodS256 is the recommended PKCE challenge method: BASE64URL(SHA256(code_verifier)).
+	CodeChallengeMethodS256 = "S256"
+	// CodeChallengeMethodPlain sends the code verifier as challenge. Use it only if S256 is not supported by the provider.
+	CodeChallengeMethodPlain = "plain"
+)
+
+// PKCE is a Proof Key for Code Exchange (RFC 7636) pair of code verifier and its challenge method.
+// It protects auth code flow of public clients (e.g CLIs) that cannot keep client secret.
+// See: https://tools.ietf.org/html/rfc7636
+//
+//	pkce, err := oidc.NewPKCE(oidc.CodeChallengeMethodS256)
+//	if err != nil {
+//	    // handle error
+//	}
+//	authURL := client.AuthCodeURL(cfg, state, pkce.AuthCodeParams())
+//	// ... after redirect.
+//	token, err := client.Exchange(ctx, cfg, code, pkce.ExchangeParams())
+type PKCE struct {
+	// CodeVerifier is a high-entropy cryptographic random string that needs to be kept secret until the code exchange.
+	CodeVerifier string
+	// Method is a code challenge method. Either CodeChallengeMethodS256 or CodeChallengeMethodPlain.
+	Method string
+}
+
+// NewCodeVerifier returns new random code verifier. It is 43 characters long (256 bits of entropy), which is the
+// minimum length allowed by RFC 7636.
+func NewCodeVerifier() string {
+	buff := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, buff); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buff)
+}
+
+// NewPKCE constructs PKCE with new random code verifier for given challenge method.
+func NewPKCE(method string) (*PKCE, error) {
+	if err := validateCodeChallengeMethod(method); err != nil {
+		return nil, err
+	}
+	return &PKCE{
+		CodeVerifier: NewCodeVerifier(),
+		Method:       method,
+	}, nil
+}
+
+func validateCodeChallengeMethod(method string) error {
+	switch method {
+	case CodeChallengeMethodS256, CodeChallengeMethodPlain:
+		return nil
+	}
+	return fmt.Errorf("oidc: unsupported PKCE code challenge method %q", method)
+}
+
+// CodeChallenge returns code challenge derived from code verifier using the challenge method.
+func (p *PKCE) CodeChallenge() string {
+	if p.Method == CodeChallengeMethodPlain {
+		return p.CodeVerifier
+	}
+	sum := sha256.Sum256([]byte(p.CodeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthCodeParams returns extra URL values that needs to be passed to AuthCodeURL.
+func (p *PKCE) AuthCodeParams() url.Values {
+	return url.Values{
+		"code_challenge":        {p.CodeChallenge()},
+		"code_challenge_method": {p.Method},
+	}
+}
+
+// ExchangeParams returns extra URL values that needs to be passed to Exchange.
+func (p *PKCE) ExchangeParams() url.Values {
+	return url.Values{
+		"code_verifier": {p.CodeVerifier},
+	}
+}
@@ -0,0 +1,36 @@
+package oidc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPKCE_CodeChallenge(t *testing.T) {
+	// Example from RFC 7636 Appendix B.
+	p := &PKCE{
+		CodeVerifier: "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk",
+		Method:       CodeChallengeMethodS256,
+	}
+	assert.Equal(t, "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM", p.CodeChallenge())
+	assert.Equal(t, "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM", p.AuthCodeParams().Get("code_challenge"))
+	assert.Equal(t, CodeChallengeMethodS256, p.AuthCodeParams().Get("code_challenge_method"))
+	assert.Equal(t, p.CodeVerifier, p.ExchangeParams().Get("code_verifier"))
+
+	p.Method = CodeChallengeMethodPlain
+	assert.Equal(t, p.CodeVerifier, p.CodeChallenge())
+}
+
+func TestNewPKCE(t *testing.T) {
+	_, err := NewPKCE("S512")
+	assert.Error(t, err)
+
+	p, err := NewPKCE(CodeChallengeMethodS256)
+	require.NoError(t, err)
+	assert.Len(t, p.CodeVerifier, 43)
+
+	p2, err := NewPKCE(CodeChallengeMethodS256)
+	require.NoError(t, err)
+	assert.NotEqual(t, p.CodeVerifier, p2.CodeVerifier)
+}
@@ -0,0 +1,221 @@
+package oidc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+// ClientMetadata is a client metadata used in Dynamic Client Registration (RFC 7591 section 2 and
+// https://openid.net/specs/openid-connect-registration-1_0.html#ClientMetadata).
+type ClientMetadata struct {
+	RedirectURIs            []string `json:"redirect_uris,omitempty"`
+	TokenEndpointAuthMethod string   `json:"token_endpoint_auth_method,omitempty"`
+	GrantTypes              []string `json:"grant_types,omitempty"`
+	ResponseTypes           []string `json:"response_types,omitempty"`
+	ApplicationType         string   `json:"application_type,omitempty"`
+	ClientName              string   `json:"client_name,omitempty"`
+	ClientURI               string   `json:"client_uri,omitempty"`
+	LogoURI                 string   `json:"logo_uri,omitempty"`
+	// Scope is a space separated list of scopes the client can request.
+	Scope     string   `json:"scope,omitempty"`
+	Contacts  []string `json:"contacts,omitempty"`
+	TOSURI    string   `json:"tos_uri,omitempty"`
+	PolicyURI string   `json:"policy_uri,omitempty"`
+	JWKSURI   string   `json:"jwks_uri,omitempty"`
+	// JWKS is client's JSON Web Key Set document passed by value e.g for private_key_jwt.
+	JWKS              json.RawMessage `json:"jwks,omitempty"`
+	SoftwareID        string          `json:"software_id,omitempty"`
+	SoftwareVersion   string          `json:"software_version,omitempty"`
+	SoftwareStatement string          `json:"software_statement,omitempty"`
+
+	IDTokenSignedResponseAlg    string `json:"id_token_signed_response_alg,omitempty"`
+	TokenEndpointAuthSigningAlg string `json:"token_endpoint_auth_signing_alg,omitempty"`
+
+	PostLogoutRedirectURIs           []string `json:"post_logout_redirect_uris,omitempty"`
+	FrontChannelLogoutURI            string   `json:"frontchannel_logout_uri,omitempty"`
+	BackChannelLogoutURI             string   `json:"backchannel_logout_uri,omitempty"`
+	BackChannelLogoutSessionRequired bool     `json:"backchannel_logout_session_required,omitempty"`
+}
+
+// ClientRegistration is a client information response returned by registration endpoint (RFC 7591 section 3.2.1).
+// It contains the registered metadata (possibly modified by the provider) and credentials of the client.
+type ClientRegistration struct {
+	ClientMetadata
+
+	ClientID              string      `json:"client_id"`
+	ClientSecret          string      `json:"client_secret,omitempty"`
+	ClientIDIssuedAt      NumericDate `json:"client_id_issued_at,omitempty"`
+	ClientSecretExpiresAt NumericDate `json:"client_secret_expires_at,omitempty"`
+
+	// RegistrationAccessToken and RegistrationClientURI are used to read, update and delete the client (RFC 7592).
+	RegistrationAccessToken string `json:"registration_access_token,omitempty"`
+	RegistrationClientURI   string `json:"registration_client_uri,omitempty"`
+}
+
+// Config returns client configuration for the registered client. First redirect URI is used as RedirectURL and client
+// authentication is derived from the registered token endpoint auth method. Private key or mTLS based authentication
+// needs to be set on returned config by the caller.
+func (r *ClientRegistration) Config() Config {
+	cfg := Config{
+		ClientID:     r.ClientID,
+		ClientSecret: r.ClientSecret,
+		Scopes:       strings.Fields(r.Scope),
+	}
+	if len(r.RedirectURIs) > 0 {
+		cfg.RedirectURL = r.RedirectURIs[0]
+	}
+
+	switch r.TokenEndpointAuthMethod {
+	case ClientAuthMethodNone:
+		cfg.ClientAuth = PublicClient{}
+	case ClientAuthMethodSecretPost:
+		cfg.ClientAuth = ClientSecretPost{}
+	case ClientAuthMethodSecretJWT:
+		cfg.ClientAuth = &ClientSecretJWT{Algorithm: jose.SignatureAlgorithm(r.TokenEndpointAuthSigningAlg)}
+	}
+	return cfg
+}
+
+// RegisterClient registers new client at provider's registration endpoint (RFC 7591). InitialAccessToken is optional
+// and needed only if the provider restricts registration.
+// Use returned registration's Config to use the client and keep RegistrationAccessToken to manage it later.
+func (c *Client) RegisterClient(ctx context.Context, metadata ClientMetadata, initialAccessToken string) (*ClientRegistration, error) {
+	registrationURL := c.Discovery().RegistrationURL
+	if registrationURL == "" {
+		return nil, errors.New("oidc: registration endpoint is not supported by this provider")
+	}
+
+	var reg ClientRegistration
+	if err := c.doRegistrationRequest(ctx, "POST", registrationURL, initialAccessToken, metadata, &reg); err != nil {
+		return nil, fmt.Errorf("oidc: cannot register client: %v", err)
+	}
+	return &reg, nil
+}
+
+// ReadClient reads current configuration of the registered client (RFC 7592 section 2.1).
+func (c *Client) ReadClient(ctx context.Context, reg *ClientRegistration) (*ClientRegistration, error) {
+	if err := reg.checkManageable(); err != nil {
+		return nil, err
+	}
+
+	var newReg ClientRegistration
+	if err := c.doRegistrationRequest(ctx, "GET", reg.RegistrationClientURI, reg.RegistrationAccessToken, nil, &newReg); err != nil {
+		return nil, fmt.Errorf("oidc: cannot read client: %v", err)
+	}
+	newReg.keepAccessToken(reg)
+	return &newReg, nil
+}
+
+// UpdateClient replaces metadata of the registered client (RFC 7592 section 2.2). Note that it is a full replacement,
+// fields omitted in metadata may be reset by the provider.
+func (c *Client) UpdateClient(ctx context.Context, reg *ClientRegistration, metadata ClientMetadata) (*ClientRegistration, error) {
+	if err := reg.checkManageable(); err != nil {
+		return nil, err
+	}
+
+	// Update request needs to include client_id and current client_secret.
+	req := struct {
+		ClientMetadata
+		ClientID     string `json:"client_id"`
+		ClientSecret string `json:"client_secret,omitempty"`
+	}{
+		ClientMetadata: metadata,
+		ClientID:       reg.ClientID,
+		ClientSecret:   reg.ClientSecret,
+	}
+	var newReg ClientRegistration
+	if err := c.doRegistrationRequest(ctx, "PUT", reg.RegistrationClientURI, reg.RegistrationAccessToken, req, &newReg); err != nil {
+		return nil, fmt.Errorf("oidc: cannot update client: %v", err)
+	}
+	newReg.keepAccessToken(reg)
+	return &newReg, nil
+}
+
+// DeleteClient deregisters the client (RFC 7592 section 2.3).
+func (c *Client) DeleteClient(ctx context.Context, reg *ClientRegistration) error {
+	if err := reg.checkManageable(); err != nil {
+		return err
+	}
+
+	if err := c.doRegistrationRequest(ctx, "DELETE", reg.RegistrationClientURI, reg.RegistrationAccessToken, nil, nil); err != nil {
+		return fmt.Errorf("oidc: cannot delete client: %v", err)
+	}
+	return nil
+}
+
+func (r *ClientRegistration) checkManageable() error {
+	if r.RegistrationClientURI == "" || r.RegistrationAccessToken == "" {
+		return errors.New("oidc: client registration has no registration_client_uri or registration_access_token, it cannot be managed")
+	}
+	return nil
+}
+
+// keepAccessToken copies management credentials from previous registration if provider didn't rotate them.
+func (r *ClientRegistration) keepAccessToken(prev *ClientRegistration) {
+	if r.RegistrationAccessToken == "" {
+		r.RegistrationAccessToken = prev.RegistrationAccessToken
+	}
+	if r.RegistrationClientURI == "" {
+		r.RegistrationClientURI = prev.RegistrationClientURI
+	}
+}
+
+// doRegistrationRequest sends JSON encoded in (if not nil) authorized with bearer accessToken (if not empty) and decodes
+// client information response into out (if not nil).
+func (c *Client) doRegistrationRequest(ctx context.Context, method string, endpoint string, accessToken string, in interface{}, out *ClientRegistration) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("marshal client metadata: %v", err)
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, endpoint, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+
+	resp, err := c.doRequest(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	// Spec says 201 for registration, 200 for read and update and 204 for delete, but some providers differ.
+	if code := resp.StatusCode; code < 200 || code > 299 {
+		return fmt.Errorf("%v\nResponse: %s", resp.Status, respBody)
+	}
+	if out == nil {
+		return nil
+	}
+
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("failed to decode client information: %v", err)
+	}
+	if out.ClientID == "" {
+		return errors.New("client information response has no client_id")
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package oidc
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/bwplotka/go-httpt/rt"
+)
+
+func (s *ClientTestSuite) TestClientRegistration() {
+	_, err := s.client.RegisterClient(s.testCtx, ClientMetadata{}, "")
+	s.Require().Error(err)
+
+	client := s.clientWithDiscovery(func(d *DiscoveryJSON) {
+		d.RegistrationURL = exampleIssuer + "/register1"
+	})
+
+	const clientURI = exampleIssuer + "/register1/client1"
+	metadata := ClientMetadata{
+		RedirectURIs:            []string{"http://127.0.0.1:8883/callback"},
+		TokenEndpointAuthMethod: ClientAuthMethodSecretPost,
+		Scope:                   "openid email",
+	}
+
+	s.s.On("POST", exampleIssuer+"/register1").Push(func(r *http.Request) (*http.Response, error) {
+		s.Equal("Bearer initial1", r.Header.Get("Authorization"))
+		s.Equal("application/json", r.Header.Get("Content-Type"))
+
+		var got ClientMetadata
+		b, err := ioutil.ReadAll(r.Body)
+		s.Require().NoError(err)
+		s.Require().NoError(json.Unmarshal(b, &got))
+		s.Equal(metadata, got)
+
+		return rt.JSONResponseFunc(http.StatusCreated, []byte(`{
+			"client_id": "client1",
+			"client_secret": "secret1",
+			"client_secret_expires_at": 0,
+			"registration_access_token": "rat1",
+			"registration_client_uri": "`+clientURI+`",
+			"redirect_uris": ["http://127.0.0.1:8883/callback"],
+			"token_endpoint_auth_method": "client_secret_post",
+			"scope": "openid email"
+		}`))(r)
+	})
+	reg, err := client.RegisterClient(s.testCtx, metadata, "initial1")
+	s.Require().NoError(err)
+	s.Equal(Config{
+		ClientID:     "client1",
+		ClientSecret: "secret1",
+		RedirectURL:  "http://127.0.0.1:8883/callback",
+		Scopes:       []string{"openid", "email"},
+		ClientAuth:   ClientSecretPost{},
+	}, reg.Config())
+	s.Equal("rat1", reg.RegistrationAccessToken)
+
+	// Read does not rotate registration access token.
+	s.s.On("GET", clientURI).Push(func(r *http.Request) (*http.Response, error) {
+		s.Equal("Bearer rat1", r.Header.Get("Authorization"))
+		return rt.JSONResponseFunc(http.StatusOK, []byte(`{"client_id": "client1", "client_secret": "secret1", "client_name": "name1"}`))(r)
+	})
+	reg, err = client.ReadClient(s.testCtx, reg)
+	s.Require().NoError(err)
+	s.Equal("name1", reg.ClientName)
+	s.Equal("rat1", reg.RegistrationAccessToken)
+	s.Equal(clientURI, reg.RegistrationClientURI)
+
+	// Update includes client credentials and rotates registration access token.
+	s.s.On("PUT", clientURI).Push(func(r *http.Request) (*http.Response, error) {
+		s.Equal("Bearer rat1", r.Header.Get("Authorization"))
+
+		got := map[string]interface{}{}
+		b, err := ioutil.ReadAll(r.Body)
+		s.Require().NoError(err)
+		s.Require().NoError(json.Unmarshal(b, &got))
+		s.Equal("client1", got["client_id"])
+		s.Equal("secret1", got["client_secret"])
+		s.Equal("name2", got["client_name"])
+
+		return rt.JSONResponseFunc(http.StatusOK, []byte(`{"client_id": "client1", "client_name": "name2", "registration_access_token": "rat2"}`))(r)
+	})
+	reg, err = client.UpdateClient(s.testCtx, reg, ClientMetadata{ClientName: "name2"})
+	s.Require().NoError(err)
+	s.Equal("rat2", reg.RegistrationAccessToken)
+
+	s.s.On("DELETE", clientURI).Push(func(r *http.Request) (*http.Response, error) {
+		s.Equal("Bearer rat2", r.Header.Get("Authorization"))
+		return rt.StringResponseFunc(http.StatusNoContent, "")(r)
+	})
+	s.Require().NoError(client.DeleteClient(s.testCtx, reg))
+
+	s.s.On("DELETE", clientURI).Push(rt.JSONResponseFunc(http.StatusUnauthorized, []byte(`{"error": "invalid_token"}`)))
+	err = client.DeleteClient(s.testCtx, reg)
+	s.Require().Error(err)
+	s.Contains(err.Error(), "oidc: cannot delete client: ")
+	s.Contains(err.Error(), `Response: {"error": "invalid_token"}`)
+
+	s.Require().Error(client.DeleteClient(s.testCtx, &ClientRegistration{ClientID: "client1"}))
+	s.Equal(0, s.s.Len())
+}
@@ -0,0 +1,71 @@
+package oidc
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// ReplayCache remembers one-time identifiers (e.g "jti" claims) until they expire, so the same token cannot be
+// accepted twice.
+// It has to be safe for concurrent use. Use shared implementation (e.g backed by Redis) if tokens can be received by
+// multiple replicas.
+type ReplayCache interface {
+	// Add stores id until expiry. It returns false if id is already stored and not expired yet, which means replay.
+	Add(id string, expiry time.Time) bool
+}
+
+type memoryReplayCache struct {
+	mu  sync.Mutex
+	ids map[string]time.Time
+	// expiries orders stored ids by expiry, so expired ones can be dropped without scanning all of them.
+	expiries replayExpiryHeap
+	timeNow  func() time.Time
+}
+
+// NewMemoryReplayCache returns in-memory ReplayCache. Expired entries are dropped lazily on Add.
+func NewMemoryReplayCache() ReplayCache {
+	return newMemoryReplayCache(time.Now)
+}
+
+func newMemoryReplayCache(now func() time.Time) *memoryReplayCache {
+	return &memoryReplayCache{ids: map[string]time.Time{}, timeNow: now}
+}
+
+// Add stores id until expiry. It returns false if id is already stored and not expired yet.
+func (c *memoryReplayCache) Add(id string, expiry time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.timeNow()
+	for len(c.expiries) > 0 && !c.expiries[0].expiry.After(now) {
+		delete(c.ids, heap.Pop(&c.expiries).(replayEntry).id)
+	}
+
+	if _, ok := c.ids[id]; ok {
+		return false
+	}
+	c.ids[id] = expiry
+	heap.Push(&c.expiries, replayEntry{id: id, expiry: expiry})
+	return true
+}
+
+type replayEntry struct {
+	id     string
+	expiry time.Time
+}
+
+// replayExpiryHeap is a min-heap of replay entries ordered by expiry. See container/heap.
+type replayExpiryHeap []replayEntry
+
+func (h replayExpiryHeap) Len() int            { return len(h) }
+func (h replayExpiryHeap) Less(i, j int) bool  { return h[i].expiry.Before(h[j].expiry) }
+func (h replayExpiryHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *replayExpiryHeap) Push(x interface{}) { *h = append(*h, x.(replayEntry)) }
+
+func (h *replayExpiryHeap) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
@@ -0,0 +1,30 @@
+package oidc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryReplayCache(t *testing.T) {
+	now := time.Now()
+	c := newMemoryReplayCache(func() time.Time { return now })
+
+	assert.True(t, c.Add("id1", now.Add(2*time.Minute)))
+	assert.True(t, c.Add("id2", now.Add(1*time.Minute)))
+	assert.False(t, c.Add("id1", now.Add(2*time.Minute)), "replay")
+
+	// id2 expired and is dropped, id1 is still stored.
+	now = now.Add(1 * time.Minute)
+	assert.True(t, c.Add("id3", now.Add(1*time.Minute)))
+	assert.Len(t, c.ids, 2)
+	assert.Len(t, c.expiries, 2)
+	assert.False(t, c.Add("id1", now.Add(2*time.Minute)), "replay")
+	assert.True(t, c.Add("id2", now.Add(1*time.Minute)), "expired id can be stored again")
+
+	now = now.Add(2 * time.Minute)
+	assert.True(t, c.Add("id4", now.Add(1*time.Minute)))
+	assert.Len(t, c.ids, 1)
+	assert.Len(t, c.expiries, 1)
+}
@@ -0,0 +1,154 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+// randomString returns base64url encoded string of n random bytes.
+func randomString(n int) string {
+	buff := make([]byte, n)
+	if _, err := io.ReadFull(rand.Reader, buff); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buff)
+}
+
+// defaultSigningAlg returns default JWS algorithm for given public key.
+func defaultSigningAlg(pub crypto.PublicKey) (jose.SignatureAlgorithm, error) {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		return jose.RS256, nil
+	case *ecdsa.PublicKey:
+		switch k.Curve {
+		case elliptic.P256():
+			return jose.ES256, nil
+		case elliptic.P384():
+			return jose.ES384, nil
+		case elliptic.P521():
+			return jose.ES512, nil
+		}
+		return "", fmt.Errorf("oidc: unsupported elliptic curve %s", k.Curve.Params().Name)
+	case ed25519.PublicKey:
+		return jose.EdDSA, nil
+	}
+	return "", fmt.Errorf("oidc: unsupported public key type %T", pub)
+}
+
+// cryptoSigner adapts crypto.Signer to jose.OpaqueSigner, so keys kept outside of the process memory
+// (e.g in HSM or KMS) can be used for signing JWTs.
+type cryptoSigner struct {
+	signer crypto.Signer
+	alg    jose.SignatureAlgorithm
+	jwk    *jose.JSONWebKey
+}
+
+// newCryptoSigner constructs opaque signer. If alg is empty, it is derived from the public key type.
+// If keyID is empty, RFC 7638 thumbprint of the public key is used.
+func newCryptoSigner(signer crypto.Signer, alg jose.SignatureAlgorithm, keyID string) (*cryptoSigner, error) {
+	if signer == nil {
+		return nil, errors.New("oidc: signer is not specified")
+	}
+
+	if alg == "" {
+		var err error
+		alg, err = defaultSigningAlg(signer.Public())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	jwk := &jose.JSONWebKey{
+		Key:       signer.Public(),
+		KeyID:     keyID,
+		Algorithm: string(alg),
+		Use:       "sig",
+	}
+	if jwk.KeyID == "" {
+		thumbprint, err := jwk.Thumbprint(crypto.SHA256)
+		if err != nil {
+			return nil, fmt.Errorf("oidc: failed to compute key thumbprint: %v", err)
+		}
+		jwk.KeyID = base64.RawURLEncoding.EncodeToString(thumbprint)
+	}
+
+	return &cryptoSigner{signer: signer, alg: alg, jwk: jwk}, nil
+}
+
+// Public returns the public key of the signer.
+func (s *cryptoSigner) Public() *jose.JSONWebKey {
+	return s.jwk
+}
+
+// Algs returns the algorithm the signer was configured with.
+func (s *cryptoSigner) Algs() []jose.SignatureAlgorithm {
+	return []jose.SignatureAlgorithm{s.alg}
+}
+
+// SignPayload signs payload using underlying crypto.Signer.
+func (s *cryptoSigner) SignPayload(payload []byte, alg jose.SignatureAlgorithm) ([]byte, error) {
+	if alg == jose.EdDSA {
+		return s.signer.Sign(rand.Reader, payload, crypto.Hash(0))
+	}
+
+	var hash crypto.Hash
+	switch alg {
+	case jose.RS256, jose.PS256, jose.ES256:
+		hash = crypto.SHA256
+	case jose.RS384, jose.PS384, jose.ES384:
+		hash = crypto.SHA384
+	case jose.RS512, jose.PS512, jose.ES512:
+		hash = crypto.SHA512
+	default:
+		return nil, fmt.Errorf("oidc: unsupported signing algorithm %s", alg)
+	}
+
+	h := hash.New()
+	h.Write(payload)
+	digest := h.Sum(nil)
+
+	switch alg {
+	case jose.PS256, jose.PS384, jose.PS512:
+		return s.signer.Sign(rand.Reader, digest, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: hash})
+	case jose.ES256, jose.ES384, jose.ES512:
+		sig, err := s.signer.Sign(rand.Reader, digest, hash)
+		if err != nil {
+			return nil, err
+		}
+		return ecdsaASN1ToJWS(sig, s.signer.Public())
+	}
+	return s.signer.Sign(rand.Reader, digest, hash)
+}
+
+// ecdsaASN1ToJWS converts ASN.1 DER ECDSA signature returned by crypto.Signer into R || S form required by JWS.
+func ecdsaASN1ToJWS(sig []byte, pub crypto.PublicKey) ([]byte, error) {
+	ecPub, ok := pub.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("oidc: expected ECDSA public key, got %T", pub)
+	}
+
+	var parsed struct {
+		R, S *big.Int
+	}
+	if _, err := asn1.Unmarshal(sig, &parsed); err != nil {
+		return nil, fmt.Errorf("oidc: malformed ECDSA signature: %v", err)
+	}
+
+	keyBytes := (ecPub.Curve.Params().BitSize + 7) / 8
+	out := make([]byte, 2*keyBytes)
+	parsed.R.FillBytes(out[:keyBytes])
+	parsed.S.FillBytes(out[keyBytes:])
+	return out, nil
+}
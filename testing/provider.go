@@ -18,6 +18,8 @@ type Request struct {
 	Method  string
 	URL     string
 	Handler func(http.ResponseWriter)
+	// Check, if not nil, is called with the actual request before Handler. Use it to assert on request parameters.
+	Check func(*http.Request)
 }
 
 type Provider struct {
@@ -41,21 +43,39 @@ func (p *Provider) Setup(t *testing.T) {
 		if r.Method != expected.Method || r.URL.EscapedPath() != expected.URL {
 			p.t.Fatalf("Request does not match expectation %s %s", expected.Method, expected.URL)
 		}
+		if expected.Check != nil {
+			expected.Check(r)
+		}
 		expected.Handler(w)
 	}))
 }
 
 func (p *Provider) MockDiscoveryCall() {
+	p.MockDiscoveryCallWith(nil)
+}
+
+// MockDiscoveryCallWith mocks discovery call with discovery document modified by modify function (if not nil).
+func (p *Provider) MockDiscoveryCallWith(modify func(d *oidc.DiscoveryJSON)) {
 	p.ExpectedRequests = append(p.ExpectedRequests, Request{
 		Method: "GET",
 		URL:    oidc.DiscoveryEndpoint,
 		Handler: func(w http.ResponseWriter) {
-			jsonDiscovery, err := json.Marshal(oidc.DiscoveryJSON{
+			discovery := oidc.DiscoveryJSON{
 				Issuer:   p.IssuerTestSrv.URL,
 				AuthURL:  p.IssuerTestSrv.URL + "/auth1",
 				TokenURL: p.IssuerTestSrv.URL + "/token1",
 				JWKSURL:  p.IssuerTestSrv.URL + "/jwks1",
-			})
+
+				RevocationURL: p.IssuerTestSrv.URL + "/revoke1",
+
+				DeviceAuthorizationURL: p.IssuerTestSrv.URL + "/device1",
+				IntrospectionURL:       p.IssuerTestSrv.URL + "/introspect1",
+				EndSessionURL:          p.IssuerTestSrv.URL + "/logout1",
+			}
+			if modify != nil {
+				modify(&discovery)
+			}
+			jsonDiscovery, err := json.Marshal(discovery)
 			require.NoError(p.t, err)
 			fmt.Fprintln(w, string(jsonDiscovery))
 		},
@@ -72,6 +92,17 @@ func (p *Provider) MockPubKeysCall(jwkSetJSON []byte) {
 	})
 }
 
+// MockPubKeysCallErr mocks failing call for provider's keys.
+func (p *Provider) MockPubKeysCallErr(statusCode int) {
+	p.ExpectedRequests = append(p.ExpectedRequests, Request{
+		Method: "GET",
+		URL:    "/jwks1",
+		Handler: func(w http.ResponseWriter) {
+			w.WriteHeader(statusCode)
+		},
+	})
+}
+
 func (p *Provider) MockTokenCall(statusCode int, token string) {
 	p.ExpectedRequests = append(p.ExpectedRequests, Request{
 		Method: "POST",
@@ -84,6 +115,52 @@ func (p *Provider) MockTokenCall(statusCode int, token string) {
 	})
 }
 
+func (p *Provider) MockDeviceAuthCall(statusCode int, resp string) {
+	p.ExpectedRequests = append(p.ExpectedRequests, Request{
+		Method: "POST",
+		URL:    "/device1",
+		Handler: func(w http.ResponseWriter) {
+			w.Header().Add("content-type", "application/json")
+			w.WriteHeader(statusCode)
+			fmt.Fprintln(w, resp)
+		},
+	})
+}
+
+func (p *Provider) MockIntrospectionCall(statusCode int, resp string) {
+	p.ExpectedRequests = append(p.ExpectedRequests, Request{
+		Method: "POST",
+		URL:    "/introspect1",
+		Handler: func(w http.ResponseWriter) {
+			w.Header().Add("content-type", "application/json")
+			w.WriteHeader(statusCode)
+			fmt.Fprintln(w, resp)
+		},
+	})
+}
+
+func (p *Provider) MockRevokeCall(statusCode int) {
+	p.ExpectedRequests = append(p.ExpectedRequests, Request{
+		Method: "POST",
+		URL:    "/revoke1",
+		Handler: func(w http.ResponseWriter) {
+			w.WriteHeader(statusCode)
+		},
+	})
+}
+
+func (p *Provider) MockPARCall(statusCode int, resp string) {
+	p.ExpectedRequests = append(p.ExpectedRequests, Request{
+		Method: "POST",
+		URL:    "/par1",
+		Handler: func(w http.ResponseWriter) {
+			w.Header().Add("content-type", "application/json")
+			w.WriteHeader(statusCode)
+			fmt.Fprintln(w, resp)
+		},
+	})
+}
+
 // NewIDToken creates new token. Feel free to override basic claims in customClaim for various tests.
 // NOTE: It is important that on every call we
 func (p *Provider) NewIDToken(clientID string, subject string, nonce string, customClaims ...interface{}) (idToken string, jwkSetJSON []byte) {
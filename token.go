@@ -2,6 +2,7 @@ package oidc
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -16,7 +17,8 @@ import (
 const tokenExpiryDelta = 10 * time.Second
 
 // Token is an Open ID Connect token's response described here:
-// http://openid.net/specs/openid-connect-core-1_0.html#TokenResponse. Token is always Bearer type.
+// http://openid.net/specs/openid-connect-core-1_0.html#TokenResponse. Token is Bearer type, unless it is bound
+// to DPoP key (see TokenType).
 // See TokenResponse for full oauth2-compatible response.
 type Token struct {
 	// AccessToken is the token that authorizes and authenticates
@@ -33,6 +35,14 @@ type Token struct {
 	// Server when using a Client, and potentially other requested Claims that helps in authorization itself.
 	// The ID Token is always represented as a JWT.
 	IDToken string `json:"id_token"`
+
+	// TokenType is "DPoP" for DPoP-bound access tokens (RFC 9449). Empty means Bearer.
+	TokenType string `json:"token_type,omitempty"`
+
+	// DPoP is a prover holding the key the access token is bound to. It is set for DPoP-bound tokens obtained by
+	// Client. It is not cached, so it needs to be set again if token is loaded from cache (login token sources do that
+	// if login.Config.DPoP is set). Use AuthorizeRequest, which returns an error if it is missing.
+	DPoP *DPoPProver `json:"-"`
 }
 
 // Claims unmarshals the raw JSON payload of the NewIDToken into a provided struct.
@@ -46,6 +56,9 @@ type Token struct {
 //		}
 //
 func (t Token) Claims(ctx context.Context, verifier Verifier, v interface{}) error {
+	if verifier == nil {
+		return errors.New("cannot get claims. Verifier is required to verify and parse NewIDToken")
+	}
 	idToken, err := verifier.Verify(ctx, t.IDToken)
 	if err != nil {
 		return fmt.Errorf("cannot get claims. Failed to verify and parse NewIDToken. Err: %v", err)
@@ -55,9 +68,28 @@ func (t Token) Claims(ctx context.Context, verifier Verifier, v interface{}) err
 }
 
 // SetAuthHeader sets the Authorization header to r using the access
-// token in t.
+// token in t. It should not be used for DPoP-bound tokens: if the proof cannot be created (e.g token loaded from cache
+// has no DPoPProver), no header is set and the error is lost. Use AuthorizeRequest instead.
 func (t *Token) SetAuthHeader(r *http.Request) {
-	r.Header.Set("Authorization", "Bearer "+t.AccessToken)
+	_ = t.AuthorizeRequest(r)
+}
+
+// AuthorizeRequest sets the Authorization header to r using the access token in t. For DPoP-bound tokens it uses
+// "DPoP" scheme and sets DPoP proof for the request method and URL. If the proof cannot be created, r is not modified.
+func (t *Token) AuthorizeRequest(r *http.Request) error {
+	if t.TokenType != TokenTypeDPoP {
+		r.Header.Set("Authorization", "Bearer "+t.AccessToken)
+		return nil
+	}
+
+	if t.DPoP == nil {
+		return errNoDPoPProver
+	}
+	if err := t.DPoP.setProof(r, t.AccessToken); err != nil {
+		return err
+	}
+	r.Header.Set("Authorization", "DPoP "+t.AccessToken)
+	return nil
 }
 
 // IsAccessTokenExpired returns true if access token expired.
@@ -69,13 +101,50 @@ func (t *Token) IsAccessTokenExpired() bool {
 }
 
 // IsValid validates oidc token by validating AccessToken and ID Token.
+// Verifier is required. Use IsAccessTokenValid for tokens without ID Token (e.g from client credentials grant).
 // If error is nil, the token is valid.
 func (t *Token) IsValid(ctx context.Context, verifier Verifier) error {
+	if verifier == nil {
+		return errors.New("token: Verifier is required to validate IDToken")
+	}
 	_, err := verifier.Verify(ctx, t.IDToken)
 	if err != nil {
 		return fmt.Errorf("token: IDToken is not valid. Err: %v", err)
 	}
+	return t.IsAccessTokenValid()
+}
+
+// IsValidAndBound is like IsValid, but it verifies ID Token with VerifyIDToken, so it additionally ensures
+// that AccessToken belongs to the ID Token.
+func (t *Token) IsValidAndBound(ctx context.Context, verifier Verifier) error {
+	if verifier == nil {
+		return errors.New("token: Verifier is required to validate IDToken")
+	}
+	if _, err := t.VerifyIDToken(ctx, verifier); err != nil {
+		return fmt.Errorf("token: IDToken is not valid. Err: %v", err)
+	}
+	return t.IsAccessTokenValid()
+}
+
+// VerifyIDToken verifies ID Token and checks that AccessToken was issued together with it using ID Token's "at_hash"
+// claim. The claim is optional for tokens returned from token endpoint, so if ID Token does not have it, only the
+// ID Token is verified.
+func (t *Token) VerifyIDToken(ctx context.Context, verifier Verifier) (*IDToken, error) {
+	idToken, err := verifier.Verify(ctx, t.IDToken)
+	if err != nil {
+		return nil, err
+	}
+	if idToken.AccessTokenHash != "" {
+		if err := idToken.VerifyAccessToken(t.AccessToken); err != nil {
+			return nil, err
+		}
+	}
+	return idToken, nil
+}
 
+// IsAccessTokenValid validates AccessToken only. Use it for tokens without ID Token, e.g from client credentials grant.
+// If error is nil, the access token is present and not expired.
+func (t *Token) IsAccessTokenValid() error {
 	if t.AccessToken == "" {
 		return errors.New("token: No AccessToken.")
 	}
@@ -97,7 +166,8 @@ type IDToken struct {
 	// initial discovery.
 	//
 	// Note: Because of a known issue with Google Accounts' implementation
-	// this value may differ when using Google.
+	// this value may differ when using Google. It also differs for multi-tenant
+	// providers configured with WithIssuerTemplate.
 	//
 	// See: https://developers.google.com/identity/protocols/OpenIDConnect#obtainuserinfo
 	Issuer string `json:"iss"`
@@ -115,13 +185,42 @@ type IDToken struct {
 	// When the token was issued by the provider.
 	IssuedAt NumericDate `json:"iat"`
 
+	// Time before which the token must not be accepted. Optional.
+	NotBefore NumericDate `json:"nbf,omitempty"`
+
+	// Time when the end user authentication occurred. Required if max_age was requested. See VerificationConfig.MaxAge.
+	AuthTime NumericDate `json:"auth_time,omitempty"`
+
+	// Authorized party - the client ID the token was issued to. Required if the token has multiple audiences.
+	AuthorizedParty string `json:"azp,omitempty"`
+
+	// Authentication Context Class Reference ("acr" claim). See VerificationConfig.RequiredACRValues.
+	AuthContextClassRef string `json:"acr,omitempty"`
+
+	// Authentication Methods References ("amr" claim), e.g "pwd" or "otp". See VerificationConfig.RequiredAMRValues.
+	AuthMethodsRefs []string `json:"amr,omitempty"`
+
+	// Tenant resolved from the issuer for multi-tenant providers. See WithIssuerTemplate.
+	// Empty if issuer template is not configured or token was issued by the issuer itself.
+	Tenant string `json:"-"`
+
 	// Initial nonce provided during the authentication redirect.
 	//
 	// If present, this package ensures this is a valid nonce.
 	Nonce string `json:"nonce"`
 
+	// AccessTokenHash is "at_hash" claim. If present, it binds the ID token to the access token issued with it.
+	// See VerifyAccessToken.
+	AccessTokenHash string `json:"at_hash,omitempty"`
+
+	// CodeHash is "c_hash" claim. If present, it binds the ID token to the authorization code issued with it.
+	// See VerifyCode.
+	CodeHash string `json:"c_hash,omitempty"`
+
 	// Raw payload of the id_token.
 	claims []byte
+	// Algorithm the id_token was signed with. It determines hash function for "at_hash" and "c_hash" claims.
+	sigAlgorithm string
 }
 
 type Audience []string
@@ -165,6 +264,35 @@ func (i *IDToken) Claims(v interface{}) error {
 	return json.Unmarshal(i.claims, v)
 }
 
+// VerifyAccessToken checks that access token was issued together with the ID token using "at_hash" claim
+// (OIDC Core 3.1.3.8 and 3.2.2.9). It returns error if ID token has no "at_hash" claim.
+func (i *IDToken) VerifyAccessToken(accessToken string) error {
+	return i.verifyHash("at_hash", i.AccessTokenHash, accessToken)
+}
+
+// VerifyCode checks that authorization code was issued together with the ID token using "c_hash" claim
+// (OIDC Core 3.3.2.10). It returns error if ID token has no "c_hash" claim.
+func (i *IDToken) VerifyCode(code string) error {
+	return i.verifyHash("c_hash", i.CodeHash, code)
+}
+
+func (i *IDToken) verifyHash(claim string, got string, value string) error {
+	if got == "" {
+		return fmt.Errorf("oidc: ID token has no %s claim", claim)
+	}
+	if i.sigAlgorithm == "" {
+		return fmt.Errorf("oidc: cannot check %s claim of ID token that was not verified", claim)
+	}
+	expected, err := TokenHash(i.sigAlgorithm, value)
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare([]byte(got), []byte(expected)) != 1 {
+		return fmt.Errorf("oidc: ID token %s claim does not match", claim)
+	}
+	return nil
+}
+
 // NumericDate represents date and time as the number of seconds since the
 // epoch, including leap seconds. Non-integer values can be represented
 // in the serialized format, but we round to the nearest second.
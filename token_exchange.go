@@ -0,0 +1,158 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const (
+	// GrantTypeTokenExchange is an OAuth 2.0 Token Exchange grant as defined in RFC 8693.
+	GrantTypeTokenExchange = "urn:ietf:params:oauth:grant-type:token-exchange"
+
+	// Token type identifiers as defined in RFC 8693 section 3.
+	TokenTypeAccessToken  = "urn:ietf:params:oauth:token-type:access_token"
+	TokenTypeRefreshToken = "urn:ietf:params:oauth:token-type:refresh_token"
+	TokenTypeIDToken      = "urn:ietf:params:oauth:token-type:id_token"
+	TokenTypeJWT          = "urn:ietf:params:oauth:token-type:jwt"
+)
+
+// TokenExchangeRequest is a Token Exchange request as described in RFC 8693 section 2.1.
+type TokenExchangeRequest struct {
+	// SubjectToken represents the identity of the party on behalf of whom the request is being made. Required.
+	SubjectToken string
+	// SubjectTokenType is a type of SubjectToken. Defaults to TokenTypeAccessToken.
+	SubjectTokenType string
+
+	// ActorToken represents the identity of the acting party (impersonation vs delegation). Optional.
+	ActorToken string
+	// ActorTokenType is a type of ActorToken. Required if ActorToken is specified.
+	ActorTokenType string
+
+	// RequestedTokenType is a type of requested token. Optional.
+	RequestedTokenType string
+
+	// Audience is a list of logical names of target services. Optional.
+	Audience []string
+	// Resource is a list of URIs of target services. Optional.
+	Resource []string
+	// Scopes are requested scopes. If empty, Config scopes are used.
+	Scopes []string
+}
+
+// ExchangeToken exchanges subject token (and optionally actor token) for a new token e.g downscoped or impersonated token
+// for a downstream service. See https://tools.ietf.org/html/rfc8693.
+// Issued token is always returned as an AccessToken of the Token, regardless of its issuedTokenType.
+func (c *Client) ExchangeToken(ctx context.Context, cfg Config, r TokenExchangeRequest) (token *Token, issuedTokenType string, err error) {
+	if err := c.Discovery().checkGrantType(GrantTypeTokenExchange); err != nil {
+		return nil, "", err
+	}
+
+	if r.SubjectToken == "" {
+		return nil, "", errors.New("oidc: subject token is required for token exchange")
+	}
+
+	subjectTokenType := r.SubjectTokenType
+	if subjectTokenType == "" {
+		subjectTokenType = TokenTypeAccessToken
+	}
+
+	v := url.Values{
+		"grant_type":         {GrantTypeTokenExchange},
+		"subject_token":      {r.SubjectToken},
+		"subject_token_type": {subjectTokenType},
+	}
+
+	if r.ActorToken != "" {
+		if r.ActorTokenType == "" {
+			return nil, "", errors.New("oidc: actor token type is required if actor token is specified")
+		}
+		v.Set("actor_token", r.ActorToken)
+		v.Set("actor_token_type", r.ActorTokenType)
+	}
+
+	if r.RequestedTokenType != "" {
+		v.Set("requested_token_type", r.RequestedTokenType)
+	}
+
+	for _, a := range r.Audience {
+		v.Add("audience", a)
+	}
+
+	for _, res := range r.Resource {
+		v.Add("resource", res)
+	}
+
+	scopes := r.Scopes
+	if len(scopes) == 0 {
+		scopes = cfg.Scopes
+	}
+	if len(scopes) > 0 {
+		v.Set("scope", strings.Join(scopes, " "))
+	}
+
+	token, tr, err := c.tokenWithResponse(ctx, cfg, v)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if tr.IssuedTokenType == "" {
+		return nil, "", errors.New("oidc: token exchange response is missing issued_token_type")
+	}
+	return token, tr.IssuedTokenType, nil
+}
+
+// TokenExchangeTokenSource is a TokenSource that exchanges token from upstream TokenSource using
+// Token Exchange (RFC 8693) on every call.
+//
+// Exchanged tokens are not ID tokens, so Verifier returns nil. Use ReuseTokenSource to cache them:
+//
+//	src, _ := oidc.NewReuseTokenSource(nil, oidc.NewTokenExchangeTokenSource(client, cfg, userTokenSource, oidc.TokenExchangeRequest{
+//		Audience: []string{"downstream-service"},
+//	}))
+type TokenExchangeTokenSource struct {
+	client   *Client
+	cfg      Config
+	upstream TokenSource
+
+	req TokenExchangeRequest
+}
+
+// NewTokenExchangeTokenSource constructs token exchange token source. SubjectToken in request is ignored and taken from
+// upstream token instead: access token by default, or ID token if SubjectTokenType is TokenTypeIDToken.
+func NewTokenExchangeTokenSource(client *Client, cfg Config, upstream TokenSource, req TokenExchangeRequest) TokenSource {
+	return &TokenExchangeTokenSource{
+		client:   client,
+		cfg:      cfg,
+		upstream: upstream,
+		req:      req,
+	}
+}
+
+// OIDCToken obtains token from upstream token source and exchanges it for a new one.
+// NOTE: Returned token is not cached. Use ReuseTokenSource for that.
+func (s *TokenExchangeTokenSource) OIDCToken(ctx context.Context) (*Token, error) {
+	upstreamToken, err := s.upstream.OIDCToken(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: failed to get upstream token for exchange: %v", err)
+	}
+
+	req := s.req
+	req.SubjectToken = upstreamToken.AccessToken
+	if req.SubjectTokenType == TokenTypeIDToken {
+		req.SubjectToken = upstreamToken.IDToken
+	}
+
+	token, _, err := s.client.ExchangeToken(ctx, s.cfg, req)
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// Verifier returns nil, since exchanged token is not an ID token.
+func (s *TokenExchangeTokenSource) Verifier() Verifier {
+	return nil
+}
@@ -0,0 +1,66 @@
+package oidc
+
+import (
+	"net/http"
+
+	"github.com/bwplotka/go-httpt/rt"
+)
+
+func (s *ClientTestSuite) TestExchangeToken() {
+	s.s.On("POST", testDiscovery.TokenURL).Push(func(req *http.Request) (*http.Response, error) {
+		s.Require().NoError(req.ParseForm())
+		s.Equal(GrantTypeTokenExchange, req.PostForm.Get("grant_type"))
+		s.Equal("subject1", req.PostForm.Get("subject_token"))
+		s.Equal(TokenTypeAccessToken, req.PostForm.Get("subject_token_type"))
+		s.Equal("actor1", req.PostForm.Get("actor_token"))
+		s.Equal(TokenTypeJWT, req.PostForm.Get("actor_token_type"))
+		s.Equal([]string{"aud1", "aud2"}, req.PostForm["audience"])
+		s.Equal("read", req.PostForm.Get("scope"))
+
+		return rt.JSONResponseFunc(http.StatusOK, []byte(`{
+			"access_token": "exchanged1",
+			"issued_token_type": "urn:ietf:params:oauth:token-type:access_token",
+			"token_type": "Bearer",
+			"expires_in": 60
+		}`))(req)
+	})
+
+	token, issuedTokenType, err := s.client.ExchangeToken(s.testCtx, Config{ClientID: "client1", ClientSecret: "secret1"}, TokenExchangeRequest{
+		SubjectToken:   "subject1",
+		ActorToken:     "actor1",
+		ActorTokenType: TokenTypeJWT,
+		Audience:       []string{"aud1", "aud2"},
+		Scopes:         []string{"read"},
+	})
+	s.Require().NoError(err)
+	s.Equal("exchanged1", token.AccessToken)
+	s.Equal(TokenTypeAccessToken, issuedTokenType)
+
+	s.Equal(0, s.s.Len())
+}
+
+func (s *ClientTestSuite) TestTokenExchangeTokenSource() {
+	s.s.On("POST", testDiscovery.TokenURL).Push(func(req *http.Request) (*http.Response, error) {
+		s.Require().NoError(req.ParseForm())
+		s.Equal("idtoken1", req.PostForm.Get("subject_token"))
+		s.Equal(TokenTypeIDToken, req.PostForm.Get("subject_token_type"))
+
+		return rt.JSONResponseFunc(http.StatusOK, []byte(`{
+			"access_token": "exchanged1",
+			"issued_token_type": "urn:ietf:params:oauth:token-type:access_token",
+			"token_type": "Bearer"
+		}`))(req)
+	})
+
+	upstream := StaticTokenSource(&Token{AccessToken: "access1", IDToken: "idtoken1"})
+	src := NewTokenExchangeTokenSource(s.client, Config{ClientID: "client1"}, upstream, TokenExchangeRequest{
+		SubjectTokenType: TokenTypeIDToken,
+	})
+
+	token, err := src.OIDCToken(s.testCtx)
+	s.Require().NoError(err)
+	s.Equal("exchanged1", token.AccessToken)
+	s.Nil(src.Verifier())
+
+	s.Equal(0, s.s.Len())
+}
@@ -13,6 +13,8 @@ import (
 //go:generate mockery -name TokenSource -case underscore
 
 // TokenSource is anything that can return an oidc token and verifier for token verification.
+// Verifier returns nil for token sources that do not issue ID tokens (e.g client credentials or token exchange).
+// Tokens of such sources are validated by their access token only (see Token.IsAccessTokenValid).
 type TokenSource interface {
 	// OIDCToken must be safe for concurrent use by multiple goroutines.
 	// The returned Token must not be modified.
@@ -23,7 +25,9 @@ type TokenSource interface {
 // ReuseTokenSource is a oidc TokenSource that holds a single token in memory
 // and validates its expiry before each call to retrieve it with
 // Token. If it's expired, it will be auto-refreshed using the
-// new TokenSource.
+// new TokenSource. Token is validated with Token.IsValidAndBound, so access token
+// not matching ID token's "at_hash" claim is not reused. If new TokenSource has no Verifier,
+// only access token is validated with Token.IsAccessTokenValid.
 type ReuseTokenSource struct {
 	new TokenSource // called when t is expired.
 	mu  sync.Mutex  // guards t
@@ -63,7 +67,12 @@ func (s *ReuseTokenSource) OIDCToken(ctx context.Context) (*Token, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	if s.t != nil {
-		err := s.t.IsValid(ctx, s.Verifier())
+		var err error
+		if verifier := s.Verifier(); verifier != nil {
+			err = s.t.IsValidAndBound(ctx, verifier)
+		} else {
+			err = s.t.IsAccessTokenValid()
+		}
 		if err == nil {
 			return s.t, nil
 		}
@@ -126,7 +135,7 @@ func (tf *TokenRefresher) OIDCToken(ctx context.Context) (*Token, error) {
 		v.Set("scope", strings.Join(tf.cfg.Scopes, " "))
 	}
 
-	tk, err := tf.client.token(ctx, tf.cfg.ClientID, tf.cfg.ClientSecret, v)
+	tk, err := tf.client.token(ctx, tf.cfg, v)
 	if err != nil {
 		return nil, err
 	}
@@ -145,7 +154,7 @@ func (tf *TokenRefresher) Verifier() Verifier {
 
 // StaticTokenSource returns a TokenSource that always returns the same token.
 // Because the provided token t is never refreshed, StaticTokenSource is only
-// useful for tokens that never expire.
+// useful for tokens that never expire. Its Verifier is nil, so ID token of t is never verified.
 func StaticTokenSource(t *Token) TokenSource {
 	return staticTokenSource{t}
 }
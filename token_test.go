@@ -60,6 +60,10 @@ func (s *ClientTestSuite) TestToken_ClaimsWithVerify() {
 	s.Equal("subject1", claims["sub"])
 
 	s.Equal(0, s.s.Len())
+
+	err = token.Claims(s.testCtx, nil, &claims)
+	s.Require().Error(err)
+	s.Equal("cannot get claims. Verifier is required to verify and parse NewIDToken", err.Error())
 }
 
 func (s *ClientTestSuite) TestToken_Valid() {
@@ -80,6 +84,72 @@ func (s *ClientTestSuite) TestToken_Valid() {
 	s.NoError(err)
 
 	s.Equal(0, s.s.Len())
+
+	// ID token is never skipped silently.
+	s.Error(token.IsValid(s.testCtx, nil))
+	s.Error(token.IsValidAndBound(s.testCtx, nil))
+}
+
+func (s *ClientTestSuite) TestToken_AccessTokenOnly() {
+	token := &Token{AccessToken: "access1", AccessTokenExpiry: time.Now().Add(1 * time.Hour)}
+	s.NoError(token.IsAccessTokenValid())
+	s.Error((&Token{}).IsAccessTokenValid())
+	s.Error((&Token{AccessToken: "access1", AccessTokenExpiry: time.Now().Add(-1 * time.Hour)}).IsAccessTokenValid())
+
+	// Token source without verifier (e.g client credentials) is validated by access token only.
+	src, _ := NewReuseTokenSource(token, StaticTokenSource(&Token{AccessToken: "access2"}))
+	got, err := src.OIDCToken(s.testCtx)
+	s.Require().NoError(err)
+	s.Equal("access1", got.AccessToken)
+
+	token.AccessTokenExpiry = time.Now().Add(-1 * time.Hour)
+	got, err = src.OIDCToken(s.testCtx)
+	s.Require().NoError(err)
+	s.Equal("access2", got.AccessToken)
+}
+
+func (s *ClientTestSuite) TestToken_ValidAndBound() {
+	builder, err := jwt.NewDefaultBuilder()
+	s.Require().NoError(err)
+	atHash, err := TokenHash("RS256", "access1")
+	s.Require().NoError(err)
+	idToken, err := builder.JWS().Claims(&IDToken{
+		Issuer:          exampleIssuer,
+		Expiry:          NewNumericDate(time.Now().Add(1 * time.Hour)),
+		Subject:         "subject1",
+		Audience:        []string{"client1"},
+		AccessTokenHash: atHash,
+	}).CompactSerialize()
+	s.Require().NoError(err)
+	jwkSetJSON, err := json.Marshal(&jose.JSONWebKeySet{Keys: []jose.JSONWebKey{builder.PublicJWK()}})
+	s.Require().NoError(err)
+
+	verifier := s.client.Verifier(VerificationConfig{ClientID: "client1"})
+	token := Token{
+		AccessToken:       "access1",
+		IDToken:           idToken,
+		AccessTokenExpiry: time.Now().Add(1 * time.Hour),
+	}
+	s.s.Push(rt.JSONResponseFunc(http.StatusOK, jwkSetJSON))
+	s.NoError(token.IsValidAndBound(s.testCtx, verifier))
+
+	// Access token issued for different ID token.
+	token.AccessToken = "access2"
+	s.s.Push(rt.JSONResponseFunc(http.StatusOK, jwkSetJSON))
+	err = token.IsValidAndBound(s.testCtx, verifier)
+	s.Require().Error(err)
+	s.Equal("token: IDToken is not valid. Err: oidc: ID token at_hash claim does not match", err.Error())
+
+	// ID token without at_hash does not bind access token.
+	token.IDToken, jwkSetJSON = s.validIDToken()
+	s.s.Push(rt.JSONResponseFunc(http.StatusOK, jwkSetJSON))
+	parsed, err := token.VerifyIDToken(s.testCtx, verifier)
+	s.Require().NoError(err)
+	s.Error(parsed.VerifyAccessToken("access2"))
+
+	s.Error((&IDToken{AccessTokenHash: atHash}).VerifyAccessToken("access1"), "ID token was not verified")
+
+	s.Equal(0, s.s.Len())
 }
 
 func (s *ClientTestSuite) TestToken_SetAuthHeader() {
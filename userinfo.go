@@ -0,0 +1,147 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+// contentTypeJWT is a content type of signed or encrypted userinfo responses.
+const contentTypeJWT = "application/jwt"
+
+// UserInfoForIDToken is like UserInfo, but it additionally ensures that the "sub" claim of the user info matches
+// the subject of given verified ID token, as required by OIDC Core 5.3.2. Encrypted responses are decrypted with
+// cfg.DecryptionKeys. Signed responses are verified with the provider's key set using cfg.SupportedSigningAlgs
+// (asymmetric algorithms from provider's userinfo_signing_alg_values_supported or RS256 if empty) and if they carry
+// "aud" claim, it needs to contain cfg.ClientID.
+func (c *Client) UserInfoForIDToken(ctx context.Context, cfg VerificationConfig, tokenSource TokenSource, idToken *IDToken) (*UserInfo, error) {
+	if idToken == nil || idToken.Subject == "" {
+		return nil, errors.New("oidc: verified ID token with subject is required")
+	}
+
+	userInfo, err := c.userInfo(ctx, cfg, tokenSource)
+	if err != nil {
+		return nil, err
+	}
+	if userInfo.Subject != idToken.Subject {
+		return nil, fmt.Errorf("oidc: userinfo subject %q does not match ID token subject %q", userInfo.Subject, idToken.Subject)
+	}
+	return userInfo, nil
+}
+
+func (c *Client) userInfo(ctx context.Context, cfg VerificationConfig, tokenSource TokenSource) (*UserInfo, error) {
+	userInfoURL := c.Discovery().UserInfoURL
+	if userInfoURL == "" {
+		return nil, errors.New("oidc: user info endpoint is not supported by this provider")
+	}
+
+	token, err := tokenSource.OIDCToken(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: get access token: %v", err)
+	}
+
+	// Resource server can require DPoP nonce, in which case request is retried once with the nonce it provided.
+	var (
+		body        []byte
+		contentType string
+	)
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequest("GET", userInfoURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("oidc: create GET request: %v", err)
+		}
+		if err := token.AuthorizeRequest(req); err != nil {
+			return nil, fmt.Errorf("oidc: authorize userinfo request: %v", err)
+		}
+
+		resp, err := c.doRequest(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		body, err = ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if token.DPoP != nil && token.DPoP.observeNonce(req.URL, resp.Header) && attempt == 0 && isDPoPNonceError(resp, body) {
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("%s: %s", resp.Status, body)
+		}
+		contentType, _, _ = mime.ParseMediaType(resp.Header.Get("Content-Type"))
+		break
+	}
+
+	if contentType == contentTypeJWT {
+		body, err = c.userInfoJWTClaims(ctx, cfg, strings.TrimSpace(string(body)))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var userInfo UserInfo
+	if err := json.Unmarshal(body, &userInfo); err != nil {
+		return nil, fmt.Errorf("oidc: failed to decode userinfo: %v", err)
+	}
+	userInfo.claims = body
+	return &userInfo, nil
+}
+
+// userInfoJWTClaims returns claims of signed, encrypted or nested signed-then-encrypted userinfo response.
+func (c *Client) userInfoJWTClaims(ctx context.Context, cfg VerificationConfig, raw string) ([]byte, error) {
+	if isJWE(raw) {
+		plaintext, err := decryptJWE(raw, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("oidc: failed to decrypt userinfo: %v", err)
+		}
+		if json.Valid(plaintext) {
+			// Encrypted only. Response is authenticated by TLS, the same as plain JSON response.
+			return plaintext, nil
+		}
+		raw = string(plaintext)
+	}
+
+	jws, err := jose.ParseSigned(raw)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: malformed userinfo jwt: %v", err)
+	}
+	payload, err := parseJWT(raw)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: malformed userinfo jwt: %v", err)
+	}
+
+	if len(cfg.SupportedSigningAlgs) == 0 {
+		cfg.SupportedSigningAlgs = defaultSigningAlgs(c.Discovery().UserInfoSigningAlgValuesSupported)
+	}
+	v := c.Verifier(cfg)
+
+	var claims struct {
+		Issuer   string   `json:"iss"`
+		Audience Audience `json:"aud"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("oidc: failed to unmarshal userinfo claims: %v", err)
+	}
+	// Signed userinfo SHOULD contain "iss" and "aud" claims (OIDC Core 5.3.2). Check them if present.
+	if claims.Issuer != "" {
+		if _, err := v.issuer.validateToken(claims.Issuer); err != nil {
+			return nil, err
+		}
+	}
+	if len(claims.Audience) > 0 && cfg.ClientID != "" && !contains(claims.Audience, cfg.ClientID) {
+		return nil, fmt.Errorf("oidc: expected userinfo Audience %q got %q", cfg.ClientID, claims.Audience)
+	}
+
+	if err := v.verifySignature(ctx, jws, payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
@@ -0,0 +1,118 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+
+	"github.com/bwplotka/go-httpt/rt"
+	"github.com/bwplotka/go-jwt"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+func userInfoJWTResponse(body string) func(*http.Request) (*http.Response, error) {
+	return func(r *http.Request) (*http.Response, error) {
+		resp, err := rt.JSONResponseFunc(http.StatusOK, []byte(body))(r)
+		if err != nil {
+			return nil, err
+		}
+		resp.Header.Set("Content-Type", "application/jwt; charset=utf-8")
+		return resp, nil
+	}
+}
+
+func (s *ClientTestSuite) TestUserInfo() {
+	tokenSource := StaticTokenSource(&Token{AccessToken: "access1"})
+
+	s.s.On("GET", testDiscovery.UserInfoURL).Push(func(r *http.Request) (*http.Response, error) {
+		s.Equal("Bearer access1", r.Header.Get("Authorization"))
+		return rt.JSONResponseFunc(http.StatusOK, []byte(`{"sub": "subject1", "email": "user@example.com"}`))(r)
+	})
+	userInfo, err := s.client.UserInfo(s.testCtx, tokenSource)
+	s.Require().NoError(err)
+	s.Equal("subject1", userInfo.Subject)
+	s.Equal("user@example.com", userInfo.Email)
+
+	// Signed userinfo.
+	builder, err := jwt.NewDefaultBuilder()
+	s.Require().NoError(err)
+	jwkSetJSON, err := json.Marshal(&jose.JSONWebKeySet{Keys: []jose.JSONWebKey{builder.PublicJWK()}})
+	s.Require().NoError(err)
+	signed, err := builder.JWS().Claims(map[string]interface{}{
+		"iss":   exampleIssuer,
+		"aud":   "client1",
+		"sub":   "subject1",
+		"email": "user@example.com",
+	}).CompactSerialize()
+	s.Require().NoError(err)
+
+	s.s.On("GET", testDiscovery.UserInfoURL).Push(userInfoJWTResponse(signed))
+	s.s.On("GET", testDiscovery.JWKSURL).Push(rt.JSONResponseFunc(http.StatusOK, jwkSetJSON))
+	userInfo, err = s.client.UserInfo(s.testCtx, tokenSource)
+	s.Require().NoError(err)
+	s.Equal("subject1", userInfo.Subject)
+	s.Equal("user@example.com", userInfo.Email)
+
+	// Signed by different key.
+	otherBuilder, err := jwt.NewDefaultBuilder()
+	s.Require().NoError(err)
+	forged, err := otherBuilder.JWS().Claims(map[string]interface{}{"sub": "subject1"}).CompactSerialize()
+	s.Require().NoError(err)
+	s.s.On("GET", testDiscovery.UserInfoURL).Push(userInfoJWTResponse(forged))
+	s.s.On("GET", testDiscovery.JWKSURL).Push(rt.JSONResponseFunc(http.StatusOK, jwkSetJSON))
+	_, err = s.client.UserInfo(s.testCtx, tokenSource)
+	s.Require().Error(err)
+
+	// Unsigned, even though "none" is supported.
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub": "subject1"}`)) + "."
+	s.s.On("GET", testDiscovery.UserInfoURL).Push(userInfoJWTResponse(unsigned))
+	_, err = s.client.UserInfoForIDToken(s.testCtx, VerificationConfig{ClientID: "client1", SupportedSigningAlgs: []string{"none"}}, tokenSource, &IDToken{Subject: "subject1"})
+	s.Require().Error(err)
+	s.Contains(err.Error(), "oidc: unsigned jwt is not allowed")
+
+	// Nested signed-then-encrypted userinfo.
+	encKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	s.Require().NoError(err)
+	encrypter, err := jose.NewEncrypter(
+		jose.A128GCM,
+		jose.Recipient{Algorithm: jose.RSA_OAEP_256, Key: &encKey.PublicKey, KeyID: "enc1"},
+		(&jose.EncrypterOptions{}).WithContentType("JWT"),
+	)
+	s.Require().NoError(err)
+	jwe, err := encrypter.Encrypt([]byte(signed))
+	s.Require().NoError(err)
+	encrypted, err := jwe.CompactSerialize()
+	s.Require().NoError(err)
+
+	cfg := VerificationConfig{
+		ClientID:       "client1",
+		DecryptionKeys: []jose.JSONWebKey{{Key: encKey, KeyID: "enc1", Use: "enc"}},
+	}
+	s.s.On("GET", testDiscovery.UserInfoURL).Push(userInfoJWTResponse(encrypted))
+	s.s.On("GET", testDiscovery.JWKSURL).Push(rt.JSONResponseFunc(http.StatusOK, jwkSetJSON))
+	userInfo, err = s.client.UserInfoForIDToken(s.testCtx, cfg, tokenSource, &IDToken{Subject: "subject1"})
+	s.Require().NoError(err)
+	s.Equal("user@example.com", userInfo.Email)
+
+	// No decryption keys.
+	s.s.On("GET", testDiscovery.UserInfoURL).Push(userInfoJWTResponse(encrypted))
+	_, err = s.client.UserInfo(s.testCtx, tokenSource)
+	s.Require().Error(err)
+	s.Equal("oidc: failed to decrypt userinfo: response is encrypted, but no DecryptionKeys are configured", err.Error())
+
+	// Userinfo for different subject.
+	s.s.On("GET", testDiscovery.UserInfoURL).Push(rt.JSONResponseFunc(http.StatusOK, []byte(`{"sub": "subject2"}`)))
+	_, err = s.client.UserInfoForIDToken(s.testCtx, cfg, tokenSource, &IDToken{Subject: "subject1"})
+	s.Require().Error(err)
+	s.Equal(`oidc: userinfo subject "subject2" does not match ID token subject "subject1"`, err.Error())
+
+	// Signed userinfo for different client.
+	s.s.On("GET", testDiscovery.UserInfoURL).Push(userInfoJWTResponse(signed))
+	cfg.ClientID = "client2"
+	_, err = s.client.UserInfoForIDToken(s.testCtx, cfg, tokenSource, &IDToken{Subject: "subject1"})
+	s.Require().Error(err)
+
+	s.Equal(0, s.s.Len())
+}
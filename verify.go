@@ -5,6 +5,10 @@ package oidc
 import (
 	"bytes"
 	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
@@ -33,7 +37,7 @@ type Verifier interface {
 type IDTokenVerifier struct {
 	keySet keySet
 	cfg    VerificationConfig
-	issuer string
+	issuer issuerValidator
 }
 
 // VerificationConfig is the configuration for an IDTokenVerifier.
@@ -45,22 +49,130 @@ type VerificationConfig struct {
 	// If not provided, users must explicitly set SkipClientIDCheck.
 	ClientID string
 
+	// If true, no ClientID check is performed (neither "aud" nor "azp"). Useful when only signature and expiry of the
+	// token needs to be validated, e.g by a resource server accepting ID tokens issued for multiple clients.
+	SkipClientIDCheck bool
+
 	// ClaimNonce for Verification.
 	ClaimNonce string
 
 	// If specified, only this set of algorithms may be used to sign the JWT.
 	//
-	// Since many providers only support RS256, SupportedSigningAlgs defaults to this value.
+	// Client.Verifier defaults it to provider's id_token_signing_alg_values_supported limited to asymmetric algorithms
+	// (RS, PS, ES and EdDSA), or to RS256 if provider does not advertise any of them. "none" and HMAC algorithms
+	// (HS256, HS384, HS512) are accepted only if listed explicitly. HMAC signatures are verified with ClientSecret.
+	// "none" is honoured only for ID tokens; logout tokens, JARM responses and userinfo need to be always signed.
 	SupportedSigningAlgs []string
 
 	// Time function to check Token expiry. Defaults to time.Now
 	Now func() time.Time
+
+	// Leeway is allowed clock skew between the provider and the client. It applies to "exp", "nbf", "iat" and
+	// "auth_time" checks.
+	Leeway time.Duration
+
+	// If non-zero, "auth_time" claim is required and the end user must have authenticated no longer than MaxAge ago.
+	// Set it to max_age used in the authentication request (OIDC Core 3.1.2.1).
+	MaxAge time.Duration
+
+	// If specified, "acr" claim is required and it needs to be one of these values.
+	RequiredACRValues []string
+
+	// If specified, "amr" claim needs to contain all of these values.
+	RequiredAMRValues []string
+
+	// If specified, "typ" header of the JWT needs to match it, e.g "JWT". Comparison is case insensitive and
+	// "application/" prefix is ignored (RFC 7515 4.1.9). If empty, "typ" header is not checked.
+	ExpectedType string
+
+	// DecryptionKeys are client's private keys used to decrypt encrypted ID tokens and responses, e.g encrypted userinfo.
+	// The provider encrypts to the public keys registered by the client (jwks or jwks_uri).
+	DecryptionKeys []jose.JSONWebKey
+
+	// ClientSecret is the key for HMAC signatures (if enabled in SupportedSigningAlgs) and it is used to derive the key
+	// for tokens encrypted with "dir" algorithm (OIDC Core 10.2).
+	ClientSecret string
+
+	// If specified, only this set of key management algorithms ("alg" header) may be used to encrypt the JWT.
+	//
+	// Defaults to RSA-OAEP, RSA-OAEP-256, ECDH-ES (with and without key wrapping) and dir.
+	SupportedKeyManagementAlgs []string
+
+	// If specified, only this set of content encryption algorithms ("enc" header) may be used to encrypt the JWT.
+	//
+	// Defaults to AES GCM and AES CBC with HMAC SHA-2 algorithms.
+	SupportedContentEncryptionAlgs []string
+}
+
+// safeSigningAlgs are asymmetric signature algorithms supported by go-jose. Only these are enabled by default.
+var safeSigningAlgs = []string{
+	string(jose.RS256), string(jose.RS384), string(jose.RS512),
+	string(jose.PS256), string(jose.PS384), string(jose.PS512),
+	string(jose.ES256), string(jose.ES384), string(jose.ES512),
+	string(jose.EdDSA),
+}
+
+// defaultSigningAlgs returns algorithms advertised by the provider that are in safeSigningAlgs. If there are none,
+// RS256 is returned, since it is the default algorithm for ID tokens.
+func defaultSigningAlgs(advertised []string) []string {
+	var algs []string
+	for _, alg := range advertised {
+		if contains(safeSigningAlgs, alg) {
+			algs = append(algs, alg)
+		}
+	}
+	if len(algs) == 0 {
+		return []string{string(jose.RS256)}
+	}
+	return algs
+}
+
+func isHMACAlg(alg string) bool {
+	switch jose.SignatureAlgorithm(alg) {
+	case jose.HS256, jose.HS384, jose.HS512:
+		return true
+	}
+	return false
+}
+
+// keyMatchesAlg returns error if key cannot be used to verify signature with given algorithm.
+func keyMatchesAlg(key jose.JSONWebKey, alg string) error {
+	if key.Algorithm != "" && key.Algorithm != alg {
+		return fmt.Errorf("oidc: key %q is meant for %s algorithm, but token is signed with %s", key.KeyID, key.Algorithm, alg)
+	}
+
+	var ok bool
+	switch jose.SignatureAlgorithm(alg) {
+	case jose.RS256, jose.RS384, jose.RS512, jose.PS256, jose.PS384, jose.PS512:
+		_, ok = key.Key.(*rsa.PublicKey)
+	case jose.ES256:
+		ok = isECDSAKeyWithCurve(key.Key, elliptic.P256())
+	case jose.ES384:
+		ok = isECDSAKeyWithCurve(key.Key, elliptic.P384())
+	case jose.ES512:
+		ok = isECDSAKeyWithCurve(key.Key, elliptic.P521())
+	case jose.EdDSA:
+		_, ok = key.Key.(ed25519.PublicKey)
+	default:
+		// Let go-jose decide.
+		return nil
+	}
+	if !ok {
+		return fmt.Errorf("oidc: key %q of type %T cannot verify %s signature", key.KeyID, key.Key, alg)
+	}
+	return nil
+}
+
+func isECDSAKeyWithCurve(key interface{}, curve elliptic.Curve) bool {
+	k, ok := key.(*ecdsa.PublicKey)
+	return ok && k.Curve == curve
 }
 
-func newVerifier(keySet keySet, cfg VerificationConfig, issuer string) *IDTokenVerifier {
-	// If SupportedSigningAlgs is empty defaults to only support RS256.
+// newVerifier constructs IDTokenVerifier. If cfg.SupportedSigningAlgs is empty, it defaults to advertisedAlgs limited to
+// asymmetric algorithms, or to RS256 if there are none (see defaultSigningAlgs).
+func newVerifier(keySet keySet, cfg VerificationConfig, issuer issuerValidator, advertisedAlgs []string) *IDTokenVerifier {
 	if len(cfg.SupportedSigningAlgs) == 0 {
-		cfg.SupportedSigningAlgs = []string{string(jose.RS256)}
+		cfg.SupportedSigningAlgs = defaultSigningAlgs(advertisedAlgs)
 	}
 
 	return &IDTokenVerifier{
@@ -93,6 +205,7 @@ func contains(sli []string, ele string) bool {
 
 // Verify parses a raw ID Token, verifies it's been signed by the provider, preforms
 // any additional checks depending on the Config, and returns the payload.
+// Encrypted ID Tokens (JWE) are decrypted with the Config's decryption keys first.
 //
 // See: https://openid.net/specs/openid-connect-core-1_0.html#IDTokenValidation
 //
@@ -104,6 +217,15 @@ func contains(sli []string, ele string) bool {
 //    token, err := verifier.Verify(ctx, oidcToken.IDToken)
 //
 func (v *IDTokenVerifier) Verify(ctx context.Context, rawIDToken string) (*IDToken, error) {
+	if isJWE(rawIDToken) {
+		// Encrypted ID token is a nested JWT: signed by the provider and then encrypted for the client.
+		plaintext, err := decryptJWE(rawIDToken, v.cfg)
+		if err != nil {
+			return nil, fmt.Errorf("oidc: failed to decrypt id token: %v", err)
+		}
+		rawIDToken = string(plaintext)
+	}
+
 	jws, err := jose.ParseSigned(rawIDToken)
 	if err != nil {
 		return nil, fmt.Errorf("oidc: malformed jwt: %v", err)
@@ -121,55 +243,200 @@ func (v *IDTokenVerifier) Verify(ctx context.Context, rawIDToken string) (*IDTok
 	}
 
 	token.claims = payload
+	token.sigAlgorithm = jws.Signatures[0].Header.Algorithm
 
-	// Check issuer.
-	if token.Issuer != v.issuer {
-		// Google sometimes returns "accounts.google.com" as the issuer claim instead of
-		// the required "https://accounts.google.com". Detect this case and allow it only
-		// for Google.
-		//
-		// We will not add hooks to let other providers go off spec like this.
-		if !(v.issuer == issuerGoogleAccounts && token.Issuer == issuerGoogleAccountsNoScheme) {
-			return nil, fmt.Errorf("oidc: id token issued by a different provider, expected %q got %q", v.issuer, token.Issuer)
+	if v.cfg.ExpectedType != "" {
+		typ, _ := jws.Signatures[0].Header.ExtraHeaders[jose.HeaderType].(string)
+		if !typeMatches(typ, v.cfg.ExpectedType) {
+			return nil, fmt.Errorf("oidc: expected typ header %q got %q", v.cfg.ExpectedType, typ)
 		}
 	}
 
-	// This check DOES NOT ensure that the ClientID is the party to which the ID Token was issued (i.e. Authorized party).
-	if v.cfg.ClientID != "" {
+	// Check issuer.
+	token.Tenant, err = v.issuer.validateToken(token.Issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	if !v.cfg.SkipClientIDCheck {
+		if v.cfg.ClientID == "" {
+			return nil, fmt.Errorf("oidc: Invalid configuration. ClientID must be provided or SkipClientIDCheck must be set")
+		}
 		if !contains(token.Audience, v.cfg.ClientID) {
 			return nil, fmt.Errorf("oidc: expected Audience %q got %q", v.cfg.ClientID, token.Audience)
 		}
-	} else {
-		return nil, fmt.Errorf("oidc: Invalid configuration. ClientID must be provided")
+
+		// Ensure that the ClientID is the party to which the ID Token was issued (OIDC Core 3.1.3.7 points 4 and 5).
+		if len(token.Audience) > 1 && token.AuthorizedParty == "" {
+			return nil, fmt.Errorf("oidc: token has multiple audiences %q, but no azp claim", token.Audience)
+		}
+		if token.AuthorizedParty != "" && token.AuthorizedParty != v.cfg.ClientID {
+			return nil, fmt.Errorf("oidc: expected Authorized Party %q got %q", v.cfg.ClientID, token.AuthorizedParty)
+		}
+	}
+
+	if err := v.verifyTimes(token.Expiry, token.NotBefore, token.IssuedAt); err != nil {
+		return nil, err
+	}
+	if err := v.verifyAuthTime(token.AuthTime); err != nil {
+		return nil, err
+	}
+
+	// Unsigned ID tokens are accepted only if explicitly enabled. Other JWTs verified by IDTokenVerifier (logout tokens,
+	// JARM responses, userinfo) need to be always signed.
+	if len(jws.Signatures) == 1 && jws.Signatures[0].Header.Algorithm == "none" && contains(v.cfg.SupportedSigningAlgs, "none") {
+		if err := verifyUnsigned(jws, payload); err != nil {
+			return nil, err
+		}
+	} else if err := v.verifySignature(ctx, jws, payload); err != nil {
+		return nil, err
+	}
+
+	// Check the nonce after we've verified the token. We don'token want to allow unverified
+	// payloads to trigger a nonce lookup.
+	if v.cfg.ClaimNonce != "" {
+		if token.Nonce != v.cfg.ClaimNonce {
+			return nil, fmt.Errorf("oidc: Invalid configuration. ClaimNonce must match. Got %s, expected %s",
+				token.Nonce, v.cfg.ClaimNonce)
+		}
+	}
+
+	if len(v.cfg.RequiredACRValues) > 0 && !contains(v.cfg.RequiredACRValues, token.AuthContextClassRef) {
+		return nil, fmt.Errorf("oidc: expected acr to be one of %q got %q", v.cfg.RequiredACRValues, token.AuthContextClassRef)
+	}
+	for _, amr := range v.cfg.RequiredAMRValues {
+		if !contains(token.AuthMethodsRefs, amr) {
+			return nil, fmt.Errorf("oidc: expected amr to contain %q got %q", amr, token.AuthMethodsRefs)
+		}
 	}
 
-	now := time.Now
+	return &token, nil
+}
+
+func (v *IDTokenVerifier) now() time.Time {
 	if v.cfg.Now != nil {
-		now = v.cfg.Now
+		return v.cfg.Now()
+	}
+	return time.Now()
+}
+
+// verifyTimes checks "exp", "nbf" and "iat" claims of the token, allowing for cfg.Leeway clock skew. Zero nbf and iat
+// are not checked.
+func (v *IDTokenVerifier) verifyTimes(expiry NumericDate, notBefore NumericDate, issuedAt NumericDate) error {
+	n := v.now()
+	leeway := v.cfg.Leeway
+
+	if expiry.Time().Add(leeway).Before(n) {
+		return fmt.Errorf("oidc: token is expired (Token Expiry: %v)", expiry.Time())
 	}
+	if notBefore != 0 && notBefore.Time().Add(-leeway).After(n) {
+		return fmt.Errorf("oidc: token is not valid yet (Token Not Before: %v)", notBefore.Time())
+	}
+	if issuedAt != 0 && issuedAt.Time().Add(-leeway).After(n) {
+		return fmt.Errorf("oidc: token is issued in the future (Token Issued At: %v)", issuedAt.Time())
+	}
+	return nil
+}
 
-	if token.Expiry.Time().Before(now()) {
-		return nil, fmt.Errorf("oidc: token is expired (Token Expiry: %v)", token.Expiry.Time())
+// verifyAuthTime checks "auth_time" claim against cfg.MaxAge, if configured.
+func (v *IDTokenVerifier) verifyAuthTime(authTime NumericDate) error {
+	if v.cfg.MaxAge <= 0 {
+		return nil
+	}
+	if authTime == 0 {
+		return errors.New("oidc: token has no auth_time claim, but MaxAge is configured")
 	}
+	if authTime.Time().Add(v.cfg.MaxAge + v.cfg.Leeway).Before(v.now()) {
+		return fmt.Errorf("oidc: end user authenticated too long ago (Auth Time: %v, MaxAge: %v)", authTime.Time(), v.cfg.MaxAge)
+	}
+	return nil
+}
 
+// typeMatches compares JWT "typ" header values as recommended by RFC 7515 4.1.9.
+func typeMatches(got string, expected string) bool {
+	normalize := func(typ string) string {
+		typ = strings.ToLower(typ)
+		return strings.TrimPrefix(typ, "application/")
+	}
+	return normalize(got) == normalize(expected)
+}
+
+// verifyUnsigned verifies that jws is unsigned JWT ("none" algorithm) carrying given payload.
+func verifyUnsigned(jws *jose.JSONWebSignature, payload []byte) error {
+	if len(jws.Signatures[0].Signature) != 0 {
+		return errors.New("oidc: unsigned jwt needs to have empty signature")
+	}
+	if !bytes.Equal(jws.UnsafePayloadWithoutVerification(), payload) {
+		return errors.New("oidc: internal error, payload parsed did not match previous payload")
+	}
+	return nil
+}
+
+// verifySignature verifies that jws is signed by one of the provider keys using supported algorithm and that it carries
+// given payload. HMAC signed JWTs are accepted only if their algorithm is explicitly supported. Unsigned ("none") JWTs
+// are always rejected, even if "none" is supported, since the function is used for logout tokens, JARM responses and
+// userinfo as well. See Verify for unsigned ID tokens.
+func (v *IDTokenVerifier) verifySignature(ctx context.Context, jws *jose.JSONWebSignature, payload []byte) error {
 	// If a set of required algorithms/keys has been provided, ensure that the signature verify will use those.
 	keyIDs := make(map[string]struct{})
 	var gotAlgsForErrLog []string
 	for _, sig := range jws.Signatures {
-		if len(v.cfg.SupportedSigningAlgs) == 0 || contains(v.cfg.SupportedSigningAlgs, sig.Header.Algorithm) {
+		if sig.Header.Algorithm == "none" && contains(v.cfg.SupportedSigningAlgs, "none") {
+			return errors.New("oidc: unsigned jwt is not allowed")
+		}
+		if sig.Header.Algorithm != "none" && (len(v.cfg.SupportedSigningAlgs) == 0 || contains(v.cfg.SupportedSigningAlgs, sig.Header.Algorithm)) {
 			keyIDs[sig.Header.KeyID] = struct{}{}
 		} else {
 			gotAlgsForErrLog = append(gotAlgsForErrLog, sig.Header.Algorithm)
 		}
 	}
 	if len(keyIDs) == 0 {
-		return nil, fmt.Errorf("oidc: no signatures use a supported algorithm, expected %q got %q", v.cfg.SupportedSigningAlgs, gotAlgsForErrLog)
+		return fmt.Errorf("oidc: no signatures use a supported algorithm, expected %q got %q", v.cfg.SupportedSigningAlgs, gotAlgsForErrLog)
+	}
+
+	alg := jws.Signatures[0].Header.Algorithm
+	var gotPayload []byte
+	switch {
+	case len(jws.Signatures) == 1 && isHMACAlg(alg):
+		if v.cfg.ClientSecret == "" {
+			return fmt.Errorf("oidc: Invalid configuration. ClientSecret is required to verify %s signature", alg)
+		}
+		p, err := jws.Verify([]byte(v.cfg.ClientSecret))
+		if err != nil {
+			return fmt.Errorf("oidc: failed to verify id token. Err: %v", err)
+		}
+		gotPayload = p
+	default:
+		p, err := v.verifyWithProviderKeys(ctx, jws, keyIDs, alg)
+		if err != nil {
+			return err
+		}
+		gotPayload = p
+	}
+
+	// Ensure that the payload returned by the square actually matches the payload parsed earlier.
+	if !bytes.Equal(gotPayload, payload) {
+		return errors.New("oidc: internal error, payload parsed did not match previous payload")
 	}
+	return nil
+}
+
+// KeySetError is returned by Verify if provider's keys could not be fetched. In that case it is unknown if the token is
+// valid, so the caller can retry later instead of treating the token as invalid.
+type KeySetError struct {
+	Err error
+}
 
+func (e *KeySetError) Error() string {
+	return fmt.Sprintf("oidc: get keys for id token: %v", e.Err)
+}
+
+// verifyWithProviderKeys verifies jws with provider's keys of given IDs and returns its payload.
+func (v *IDTokenVerifier) verifyWithProviderKeys(ctx context.Context, jws *jose.JSONWebSignature, keyIDs map[string]struct{}, alg string) ([]byte, error) {
 	// Get keys from the remote key set. This will always trigger a re-sync.
 	allKeys, err := v.keySet.Keys(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("oidc: get keys for id token: %v", err)
+		return nil, &KeySetError{Err: err}
 	}
 
 	var keys []jose.JSONWebKey
@@ -183,35 +450,20 @@ func (v *IDTokenVerifier) Verify(ctx context.Context, rawIDToken string) (*IDTok
 		return nil, fmt.Errorf("oidc: no keys match signature ID(s) %v. Got keys: %v", keyIDs, allKeys)
 	}
 
-	// Try to use a key to validate the signature.
-	var gotPayload []byte
+	// Try to use a key to validate the signature. Keys that cannot be used with the algorithm are reported as such,
+	// instead of failing with cryptic error.
 	xerr := xerrors.New()
 	for _, key := range keys {
+		if err := keyMatchesAlg(key, alg); err != nil {
+			xerr.Add(err)
+			continue
+		}
 		p, err := jws.Verify(&key)
 		if err != nil {
 			xerr.Add(err)
 			continue
 		}
-		gotPayload = p
-		break
-	}
-	if len(gotPayload) == 0 {
-		return nil, fmt.Errorf("oidc: failed to verify id token. Err: %v", xerr.ErrorOrNil())
+		return p, nil
 	}
-
-	// Ensure that the payload returned by the square actually matches the payload parsed earlier.
-	if !bytes.Equal(gotPayload, payload) {
-		return nil, errors.New("oidc: internal error, payload parsed did not match previous payload")
-	}
-
-	// Check the nonce after we've verified the token. We don'token want to allow unverified
-	// payloads to trigger a nonce lookup.
-	if v.cfg.ClaimNonce != "" {
-		if token.Nonce != v.cfg.ClaimNonce {
-			return nil, fmt.Errorf("oidc: Invalid configuration. ClaimNonce must match. Got %s, expected %s",
-				token.Nonce, v.cfg.ClaimNonce)
-		}
-	}
-
-	return &token, nil
+	return nil, fmt.Errorf("oidc: failed to verify id token. Err: %v", xerr.ErrorOrNil())
 }
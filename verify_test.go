@@ -0,0 +1,324 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+func TestVerify_SigningAlgs(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	claims, err := json.Marshal(map[string]interface{}{
+		"iss": exampleIssuer,
+		"aud": "client1",
+		"sub": "subject1",
+		"exp": time.Now().Add(1 * time.Minute).Unix(),
+	})
+	require.NoError(t, err)
+
+	sign := func(alg jose.SignatureAlgorithm, key interface{}, keyID string) string {
+		signer, err := jose.NewSigner(jose.SigningKey{Algorithm: alg, Key: key}, (&jose.SignerOptions{}).WithHeader("kid", keyID))
+		require.NoError(t, err)
+		jws, err := signer.Sign(claims)
+		require.NoError(t, err)
+		token, err := jws.CompactSerialize()
+		require.NoError(t, err)
+		return token
+	}
+
+	newClient := func(algs ...string) *Client {
+		return &Client{
+			issuer:    issuerValidator{issuer: exampleIssuer},
+			discovery: DiscoveryJSON{Issuer: exampleIssuer, IDTokenSigningAlgValuesSupported: algs},
+			keySet: staticKeySet{
+				{Key: rsaKey.Public(), KeyID: "rsa1", Use: "sig"},
+				{Key: ecKey.Public(), KeyID: "ec1", Use: "sig"},
+			},
+		}
+	}
+
+	t.Run("ES256 advertised by provider", func(t *testing.T) {
+		c := newClient("ES256", "HS256", "none")
+
+		_, err := c.Verifier(VerificationConfig{ClientID: "client1"}).Verify(context.Background(), sign(jose.ES256, ecKey, "ec1"))
+		require.NoError(t, err)
+
+		// RS256 is not advertised.
+		_, err = c.Verifier(VerificationConfig{ClientID: "client1"}).Verify(context.Background(), sign(jose.RS256, rsaKey, "rsa1"))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no signatures use a supported algorithm")
+	})
+
+	t.Run("RS256 when nothing safe is advertised", func(t *testing.T) {
+		c := newClient("HS256", "none")
+
+		_, err := c.Verifier(VerificationConfig{ClientID: "client1"}).Verify(context.Background(), sign(jose.RS256, rsaKey, "rsa1"))
+		require.NoError(t, err)
+	})
+
+	t.Run("HS256", func(t *testing.T) {
+		c := newClient("RS256", "HS256")
+		token := sign(jose.HS256, []byte("secret1"), "")
+
+		_, err := c.Verifier(VerificationConfig{ClientID: "client1", ClientSecret: "secret1"}).Verify(context.Background(), token)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no signatures use a supported algorithm")
+
+		_, err = c.Verifier(VerificationConfig{ClientID: "client1", SupportedSigningAlgs: []string{"HS256"}}).Verify(context.Background(), token)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "ClientSecret is required to verify HS256 signature")
+
+		_, err = c.Verifier(VerificationConfig{ClientID: "client1", SupportedSigningAlgs: []string{"HS256"}, ClientSecret: "secret2"}).Verify(context.Background(), token)
+		require.Error(t, err)
+
+		_, err = c.Verifier(VerificationConfig{ClientID: "client1", SupportedSigningAlgs: []string{"HS256"}, ClientSecret: "secret1"}).Verify(context.Background(), token)
+		require.NoError(t, err)
+	})
+
+	t.Run("none", func(t *testing.T) {
+		c := newClient("RS256", "none")
+		token := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." + base64.RawURLEncoding.EncodeToString(claims) + "."
+
+		_, err := c.Verifier(VerificationConfig{ClientID: "client1"}).Verify(context.Background(), token)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no signatures use a supported algorithm")
+
+		_, err = c.Verifier(VerificationConfig{ClientID: "client1", SupportedSigningAlgs: []string{"none"}}).Verify(context.Background(), token)
+		require.NoError(t, err)
+	})
+
+	t.Run("key type does not match algorithm", func(t *testing.T) {
+		c := newClient("RS256", "ES256")
+
+		// Token claims to be signed with EC key, but uses RSA key ID.
+		_, err := c.Verifier(VerificationConfig{ClientID: "client1"}).Verify(context.Background(), sign(jose.ES256, ecKey, "rsa1"))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `key "rsa1" of type *rsa.PublicKey cannot verify ES256 signature`)
+	})
+}
+
+func TestVerify_Claims(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	c := &Client{
+		issuer:    issuerValidator{issuer: exampleIssuer},
+		discovery: DiscoveryJSON{Issuer: exampleIssuer},
+		keySet:    staticKeySet{{Key: rsaKey.Public(), KeyID: "rsa1", Use: "sig"}},
+	}
+	now := time.Now()
+
+	sign := func(typ string, extra map[string]interface{}) string {
+		claims := map[string]interface{}{
+			"iss": exampleIssuer,
+			"aud": "client1",
+			"sub": "subject1",
+			"iat": now.Unix(),
+			"exp": now.Add(1 * time.Minute).Unix(),
+		}
+		for k, v := range extra {
+			claims[k] = v
+		}
+		payload, err := json.Marshal(claims)
+		require.NoError(t, err)
+
+		opts := (&jose.SignerOptions{}).WithHeader("kid", "rsa1")
+		if typ != "" {
+			opts = opts.WithType(jose.ContentType(typ))
+		}
+		signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: rsaKey}, opts)
+		require.NoError(t, err)
+		jws, err := signer.Sign(payload)
+		require.NoError(t, err)
+		token, err := jws.CompactSerialize()
+		require.NoError(t, err)
+		return token
+	}
+
+	for _, tcase := range []struct {
+		name   string
+		cfg    VerificationConfig
+		typ    string
+		claims map[string]interface{}
+		err    string
+	}{
+		{
+			name: "expired",
+			cfg:  VerificationConfig{ClientID: "client1"},
+			claims: map[string]interface{}{
+				"exp": now.Add(-10 * time.Second).Unix(),
+			},
+			err: "oidc: token is expired",
+		},
+		{
+			name: "expired within leeway",
+			cfg:  VerificationConfig{ClientID: "client1", Leeway: 30 * time.Second},
+			claims: map[string]interface{}{
+				"exp": now.Add(-10 * time.Second).Unix(),
+			},
+		},
+		{
+			name: "not valid yet",
+			cfg:  VerificationConfig{ClientID: "client1"},
+			claims: map[string]interface{}{
+				"nbf": now.Add(10 * time.Second).Unix(),
+			},
+			err: "oidc: token is not valid yet",
+		},
+		{
+			name: "not valid yet within leeway",
+			cfg:  VerificationConfig{ClientID: "client1", Leeway: 30 * time.Second},
+			claims: map[string]interface{}{
+				"nbf": now.Add(10 * time.Second).Unix(),
+			},
+		},
+		{
+			name: "issued in the future",
+			cfg:  VerificationConfig{ClientID: "client1", Leeway: 30 * time.Second},
+			claims: map[string]interface{}{
+				"iat": now.Add(1 * time.Minute).Unix(),
+			},
+			err: "oidc: token is issued in the future",
+		},
+		{
+			name: "no client ID",
+			cfg:  VerificationConfig{},
+			err:  "oidc: Invalid configuration. ClientID must be provided or SkipClientIDCheck must be set",
+		},
+		{
+			name: "skip client ID check",
+			cfg:  VerificationConfig{SkipClientIDCheck: true},
+			claims: map[string]interface{}{
+				"aud": []string{"client2", "client3"},
+				"azp": "client2",
+			},
+		},
+		{
+			name: "multiple audiences without azp",
+			cfg:  VerificationConfig{ClientID: "client1"},
+			claims: map[string]interface{}{
+				"aud": []string{"client1", "client2"},
+			},
+			err: "oidc: token has multiple audiences",
+		},
+		{
+			name: "azp is other client",
+			cfg:  VerificationConfig{ClientID: "client1"},
+			claims: map[string]interface{}{
+				"aud": []string{"client1", "client2"},
+				"azp": "client2",
+			},
+			err: `oidc: expected Authorized Party "client1" got "client2"`,
+		},
+		{
+			name: "multiple audiences with azp",
+			cfg:  VerificationConfig{ClientID: "client1"},
+			claims: map[string]interface{}{
+				"aud": []string{"client1", "client2"},
+				"azp": "client1",
+			},
+		},
+		{
+			name: "max age without auth_time",
+			cfg:  VerificationConfig{ClientID: "client1", MaxAge: 5 * time.Minute},
+			err:  "oidc: token has no auth_time claim",
+		},
+		{
+			name: "max age exceeded",
+			cfg:  VerificationConfig{ClientID: "client1", MaxAge: 5 * time.Minute},
+			claims: map[string]interface{}{
+				"auth_time": now.Add(-10 * time.Minute).Unix(),
+			},
+			err: "oidc: end user authenticated too long ago",
+		},
+		{
+			name: "max age",
+			cfg:  VerificationConfig{ClientID: "client1", MaxAge: 5 * time.Minute},
+			claims: map[string]interface{}{
+				"auth_time": now.Add(-1 * time.Minute).Unix(),
+			},
+		},
+		{
+			name: "acr not allowed",
+			cfg:  VerificationConfig{ClientID: "client1", RequiredACRValues: []string{"urn:mace:incommon:iap:silver"}},
+			claims: map[string]interface{}{
+				"acr": "0",
+			},
+			err: "oidc: expected acr to be one of",
+		},
+		{
+			name: "amr missing",
+			cfg:  VerificationConfig{ClientID: "client1", RequiredAMRValues: []string{"pwd", "otp"}},
+			claims: map[string]interface{}{
+				"amr": []string{"pwd"},
+			},
+			err: `oidc: expected amr to contain "otp"`,
+		},
+		{
+			name: "acr and amr",
+			cfg: VerificationConfig{
+				ClientID:          "client1",
+				RequiredACRValues: []string{"urn:mace:incommon:iap:silver"},
+				RequiredAMRValues: []string{"pwd", "otp"},
+			},
+			claims: map[string]interface{}{
+				"acr": "urn:mace:incommon:iap:silver",
+				"amr": []string{"otp", "pwd"},
+			},
+		},
+		{
+			name: "missing typ",
+			cfg:  VerificationConfig{ClientID: "client1", ExpectedType: "JWT"},
+			err:  `oidc: expected typ header "JWT" got ""`,
+		},
+		{
+			name: "wrong typ",
+			cfg:  VerificationConfig{ClientID: "client1", ExpectedType: "JWT"},
+			typ:  "logout+jwt",
+			err:  `oidc: expected typ header "JWT" got "logout+jwt"`,
+		},
+		{
+			name: "typ",
+			cfg:  VerificationConfig{ClientID: "client1", ExpectedType: "JWT"},
+			typ:  "application/jwt",
+		},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			_, err := c.Verifier(tcase.cfg).Verify(context.Background(), sign(tcase.typ, tcase.claims))
+			if tcase.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tcase.err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+
+	idToken, err := c.Verifier(VerificationConfig{ClientID: "client1"}).Verify(context.Background(), sign("", map[string]interface{}{
+		"nbf":       now.Unix(),
+		"auth_time": now.Add(-1 * time.Minute).Unix(),
+		"azp":       "client1",
+		"acr":       "1",
+		"amr":       []string{"pwd"},
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, NewNumericDate(now), idToken.NotBefore)
+	assert.Equal(t, NewNumericDate(now.Add(-1*time.Minute)), idToken.AuthTime)
+	assert.Equal(t, "client1", idToken.AuthorizedParty)
+	assert.Equal(t, "1", idToken.AuthContextClassRef)
+	assert.Equal(t, []string{"pwd"}, idToken.AuthMethodsRefs)
+}